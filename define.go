@@ -2,10 +2,13 @@ package main
 
 import (
 	"bytes"
+	"image"
+	"strconv"
 	"time"
 
 	define2 "github.com/shamaton/msgpackgen/testdata/define"
 	. "github.com/shamaton/msgpackgen/testdata/define/define"
+	"github.com/shamaton/msgpackgen/testdata/define/v2"
 )
 
 //go:generate go run github.com/shamaton/msgpackgen -s -p 2 -v -g resolver_test.go
@@ -93,10 +96,282 @@ type TestingStruct struct {
 	TmpPointer *Inside
 }
 
+// TestingResolve has fields which can be resolved only with type information.
+type TestingResolve struct {
+	// define.Time, not time.Time
+	Holder TimeHolder
+	// package name is different from the directory
+	Versioned versioned.Versioned
+}
+
+// named types except struct are encoded as their underlying types.
+type (
+	Status uint8
+	UserID int64
+	Name   string
+	Raw    []byte
+	IDs    []UserID
+	Tags   []string
+	Pair   [2]Name
+	Labels map[Name]Status
+)
+
+type TestingNamed struct {
+	Status   Status
+	ID       UserID
+	Name     Name
+	Raw      Raw
+	IDs      IDs
+	Tags     Tags
+	Pair     Pair
+	Labels   Labels
+	Level    Level
+	PStatus  *Status
+	Statuses []Status
+	ByID     map[UserID]Tags
+	Nested   []map[Name]*IDs
+	Grid     [2][]Pair
+}
+
+// TestingAnonymous has anonymous struct fields.
+type TestingAnonymous struct {
+	Inner struct {
+		Int  int
+		Name string `msgpack:"name"`
+		Deep struct {
+			Time  time.Time
+			Child B
+		}
+	}
+	Slice   []struct{ Int int }
+	Map     map[string]*struct{ Inside }
+	Pointer *struct {
+		Ints [2]int
+	}
+	Same struct{ Int int }
+}
+
+// TestingInterface has fields encoded by their dynamic types.
+type TestingInterface struct {
+	Int     interface{}
+	Nil     interface{}
+	Any     any
+	Slice   []interface{}
+	Map     map[string]interface{}
+	Struct  interface{}
+	Pointer *interface{}
+}
+
+// TestingInline has embedded structs flattened into itself.
+type TestingInline struct {
+	// Int is shadowed by TestingInline.Int
+	Inside      `msgpack:",inline"`
+	InlineChild `msgpack:",inline"`
+	Int         int
+	// not embedded
+	Nested Inside
+}
+
+type InlineChild struct {
+	Child      string `msgpack:"child"`
+	InlineDeep `msgpack:",inline"`
+}
+
+type InlineDeep struct {
+	Deep int
+	Int  int
+}
+
+// Page is a generic struct. codecs are generated for each instantiation.
+type Page[T any] struct {
+	Items []T
+	Next  string
+}
+
+type KeyValue[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+type List[T any] []T
+
+// TestingGeneric has instantiated generic fields.
+type TestingGeneric struct {
+	Ints    Page[int]
+	Insides Page[Inside]
+	Nested  Page[Page[string]]
+	Pair    *KeyValue[string, []Status]
+	Outer   define2.Wrapper[B]
+	List    List[Name]
+}
+
+// not used by fields, but listed
+var _ Page[float64]
+
+// aliases are resolved to their targets.
+type (
+	AliasInside    = Inside
+	AliasInt       = int
+	AliasVersioned = versioned.Versioned
+	Point          = image.Point
+	StringsPage    = Page[[]string]
+	AliasAnonymous = struct{ Int AliasInt }
+)
+
+type TestingAlias struct {
+	Inside    AliasInside
+	Int       AliasInt
+	Ints      []AliasInt
+	Versioned *AliasVersioned
+	Point     Point
+	Page      StringsPage
+	Anonymous AliasAnonymous
+	Map       map[AliasInt]AliasInside
+}
+
+// IgnoredByDirective is not generated.
+//
+//msgpackgen:ignore
+type IgnoredByDirective struct {
+	Int int
+}
+
+//msgpackgen:array
+type DirectiveArray struct {
+	Int    int
+	String string
+}
+
+//msgpackgen:map
+type DirectiveMap struct {
+	Int    int
+	String string
+}
+
+//msgpackgen:key snake
+type DirectiveKey struct {
+	UserID     int
+	HTTPServer string
+	Tagged     int `msgpack:"Tagged"`
+}
+
+// TestingIndex pins fields to slots in array layout.
+type TestingIndex struct {
+	A int    `msgpack:"a,idx=2"`
+	B string `msgpack:",idx=0"`
+	C int
+	// slots 3 to 5 are a gap
+	D bool `msgpack:",idx=6"`
+}
+
+// TestingOmitEmpty leaves out empty fields in map layout.
+type TestingOmitEmpty struct {
+	Int       int            `msgpack:",omitempty"`
+	Uint      uint8          `msgpack:"uint,omitempty"`
+	Float     float64        `msgpack:",omitempty"`
+	Bool      bool           `msgpack:",omitempty"`
+	String    string         `msgpack:",omitempty"`
+	Status    Status         `msgpack:",omitempty"`
+	Slice     []int          `msgpack:",omitempty"`
+	Map       map[string]int `msgpack:",omitempty"`
+	Pointer   *Inside        `msgpack:",omitempty"`
+	Interface interface{}    `msgpack:",omitempty"`
+	Time      time.Time      `msgpack:",omitempty"`
+	// structs are always encoded
+	Inside Inside
+	Always int
+}
+
+// EvolvableOld and EvolvableNew are versions of the same struct in array layout.
+//
+//msgpackgen:evolvable
+type EvolvableOld struct {
+	A int
+	B string
+}
+
+//msgpackgen:evolvable
+type EvolvableNew struct {
+	A int
+	B string
+	// D takes slot 2, and slots 3 and 4 are a gap
+	C []int `msgpack:",idx=5"`
+	D *Inside
+}
+
+// TestingDirective has structs with directives.
+type TestingDirective struct {
+	Array  DirectiveArray
+	Map    *DirectiveMap
+	Key    DirectiveKey
+	Inside Inside
+}
+
 type Inside struct {
 	Int int
 }
 
+// InsideNewer is Inside with fields added by a newer writer.
+type InsideNewer struct {
+	Unknown map[string][]interface{}
+	Int     int
+	Bytes   []byte
+}
+
+// TestingOrder and TestingOrderText differ in the type of Price, to check paths in decode errors.
+type TestingOrder struct {
+	Items  []*TestingItem
+	Prices map[string]TestingItem
+}
+
+type TestingItem struct {
+	Price int
+}
+
+type TestingOrderText struct {
+	Items  []*TestingItemText
+	Prices map[string]TestingItemText
+}
+
+type TestingItemText struct {
+	Price string
+}
+
+// TestingMethod declares MarshalBinary, so that the pair of MarshalBinary is not generated.
+type TestingMethod struct {
+	Int int
+}
+
+func (v TestingMethod) MarshalBinary() ([]byte, error) {
+	return []byte(strconv.Itoa(v.Int)), nil
+}
+
+// Decimal is encoded as ext 5 by the field tag, and Money as ext 6 by the directive.
+type Decimal struct {
+	Unscaled int64
+	Scale    uint8
+}
+
+//msgpackgen:ext 6
+type Money struct {
+	Currency string
+	Amount   int64
+}
+
+type TestingExt struct {
+	Price     Decimal  `msgpack:",ext=5"`
+	Discount  *Decimal `msgpack:",ext=5"`
+	Total     Money
+	Totals    []Money
+	Interface interface{}
+}
+
+// TestingShort is decoded from data whose structure differs from the one framed by the stream decoder.
+type TestingShort struct {
+	B []byte
+	N uint64
+}
+
 type Recursive struct {
 	Int int
 	R   *Recursive
@@ -112,7 +387,7 @@ func (p *Private) SetInt() {
 
 type NotGenerated1 struct {
 	Int       int
-	Interface interface{}
+	Interface interface{ Method() }
 }
 
 type NotGenerated2 struct {
@@ -132,7 +407,8 @@ type NotGenerated4 struct {
 
 type NotGenerated5 struct {
 	InnerStruct struct {
-		Int int
+		Int  int
+		Chan chan int
 	}
 	Int int
 }
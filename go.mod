@@ -1,9 +1,12 @@
 module github.com/shamaton/msgpackgen
 
-go 1.13
+go 1.22.0
 
 require (
 	github.com/dave/jennifer v1.4.1
 	github.com/shamaton/msgpack v1.1.1
-	golang.org/x/tools v0.1.0
+	golang.org/x/mod v0.21.0
+	golang.org/x/tools v0.26.0
 )
+
+require golang.org/x/sync v0.8.0 // indirect
@@ -3,424 +3,592 @@ package generator
 import (
 	"fmt"
 	"go/ast"
-	"go/parser"
 	"go/token"
 	"go/types"
-	"io/ioutil"
-	"math/big"
+	"math"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
-	"unicode"
 
 	"github.com/shamaton/msgpackgen/internal/generator/structure"
-	"golang.org/x/tools/go/gcexportdata"
+	"golang.org/x/tools/go/packages"
 )
 
-func (g *generator) getPackages(files []string) error {
-	g.fileSet = token.NewFileSet()
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo
 
-	for _, file := range files {
+func (g *generator) getPackages(dirs []string, outputFile string) error {
 
-		dir := filepath.Dir(file)
-		importPath, err := getImportPath(dir)
-		if err != nil {
-			return err
-		}
-		prefix := importPath
+	conf := &packages.Config{
+		Mode: loadMode,
+		Dir:  dirs[0],
+	}
+	pkgs, err := packages.Load(conf, dirs...)
+	if err != nil {
+		return err
+	}
 
-		source, err := ioutil.ReadFile(file)
-		if err != nil {
-			return err
-		}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].PkgPath < pkgs[j].PkgPath })
 
-		parseFile, err := parser.ParseFile(g.fileSet, file, source, parser.AllErrors)
-		if err != nil {
+	for _, pkg := range pkgs {
+		if err := g.checkPackageErrors(pkg, outputFile); err != nil {
 			return err
 		}
 
-		var packageName string
-		ast.Inspect(parseFile, func(n ast.Node) bool {
-
-			switch x := n.(type) {
-			case *ast.File:
-				packageName = x.Name.String()
-				//fmt.Println(x.Name)
-			}
-
-			return true
-		})
-
-		if dir == g.outputDir {
-			g.outputPackagePrefix = filepath.Dir(prefix)
-			g.outputPackageName = packageName
-			g.noUserQualMap[prefix] = true
-		} else if packageName == "main" {
+		if pkg.PkgPath == g.outputImportPath {
+			g.outputPackageName = pkg.Name
+			g.outputFset = pkg.Fset
+		} else if pkg.Name == "main" {
 			if g.verbose {
-				fmt.Println("skipping other main package ", file)
+				fmt.Println("skipping other main package ", pkg.PkgPath)
 			}
 			continue
 		}
+		g.packages = append(g.packages, pkg)
+	}
+	return nil
+}
 
-		g.parseFiles = append(g.parseFiles, parseFile)
-		g.parseFile2ImportPath[parseFile] = prefix
-		g.importPath2package[prefix] = packageName
-		g.targetPackages[packageName] = true
-		if _, ok := g.importPath2ParseFiles[prefix]; !ok {
-			g.importPath2ParseFiles[prefix] = make([]*ast.File, 0)
+// checkPackageErrors ignores errors in the file to be generated, because it will be overwritten.
+func (g *generator) checkPackageErrors(pkg *packages.Package, outputFile string) error {
+	var messages []string
+	for _, e := range pkg.Errors {
+		pos := e.Pos
+		if i := strings.Index(pos, ".go:"); i >= 0 {
+			pos = pos[:i+len(".go")]
+		}
+		if pos != "" && filepath.Clean(pos) == outputFile {
+			continue
 		}
-		g.importPath2ParseFiles[prefix] = append(g.importPath2ParseFiles[prefix], parseFile)
+		messages = append(messages, e.Error())
+	}
+	if len(messages) > 0 {
+		return fmt.Errorf("can not load package %s\n%s", pkg.PkgPath, strings.Join(messages, "\n"))
 	}
 	return nil
 }
 
 func (g *generator) analyze() error {
-	analyzedMap := map[*ast.File]bool{}
-	for _, parseFile := range g.parseFiles {
-
-		fullPackageName, ok := g.parseFile2ImportPath[parseFile]
-		if !ok {
-			return fmt.Errorf("not found fullPackageName")
-		}
-		packageName, ok := g.importPath2package[fullPackageName]
-		if !ok {
-			return fmt.Errorf("not found package name")
+	for _, pkg := range g.packages {
+		if err := g.readDirectives(pkg); err != nil {
+			return err
 		}
+	}
 
-		err := g.createAnalyzedStructs(parseFile, packageName, fullPackageName, analyzedMap)
+	var aliases []*types.TypeName
+	for _, pkg := range g.packages {
+		as, err := g.createAnalyzedStructs(pkg)
 		if err != nil {
 			return err
 		}
+		aliases = append(aliases, as...)
 	}
 
-	return g.setFieldToStructs()
-}
-
-func (g *generator) createAnalyzedStructs(parseFile *ast.File, packageName, importPath string, analyzedMap map[*ast.File]bool) error {
-	// done analysis
-	if _, ok := analyzedMap[parseFile]; ok {
-		return nil
+	// after all packages, so that targets are not duplicated
+	for _, alias := range aliases {
+		if err := g.createAliasTarget(alias); err != nil {
+			return err
+		}
 	}
 
-	importMap, dotImports := g.createImportMap(parseFile)
-	// dot imports
-	dotStructs := map[string]*structure.Structure{}
-	for _, dotImport := range dotImports {
-		pfs, ok := g.importPath2ParseFiles[dotImport]
-		if !ok {
-			continue
-		}
-		name, ok := g.importPath2package[dotImport]
-		if !ok {
-			continue
+	// anonymous structs are appended while analyzing fields
+	for i := 0; i < len(analyzedStructs); i++ {
+		if err := g.setFieldToStruct(analyzedStructs[i]); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		for _, pf := range pfs {
-			err := g.createAnalyzedStructs(pf, name, dotImport, analyzedMap)
-			if err != nil {
-				return err
+// createAnalyzedStructs creates structures defined in pkg and returns aliases in pkg.
+func (g *generator) createAnalyzedStructs(pkg *packages.Package) ([]*types.TypeName, error) {
+	var aliases []*types.TypeName
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
 			}
-			analyzedMap[pf] = true
-		}
 
-		for _, st := range analyzedStructs {
-			if st.ImportPath == dotImport {
-				dotStructs[st.Name] = st
+			if genDecl.Tok == token.VAR {
+				for _, spec := range genDecl.Specs {
+					if err := g.createListedInstances(pkg, spec.(*ast.ValueSpec)); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+			if genDecl.Tok != token.TYPE {
+				continue
 			}
-		}
-	}
 
-	structNames := make([]string, 0)
-	ast.Inspect(parseFile, func(n ast.Node) bool {
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				obj, ok := pkg.TypesInfo.Defs[typeSpec.Name].(*types.TypeName)
+				if !ok {
+					continue
+				}
+				if pkg.PkgPath != g.outputImportPath && !obj.Exported() {
+					continue
+				}
+				if obj.IsAlias() {
+					aliases = append(aliases, obj)
+					continue
+				}
 
-		x, ok := n.(*ast.TypeSpec)
-		if !ok {
-			return true
+				named, ok := obj.Type().(*types.Named)
+				if !ok || named.TypeParams().Len() > 0 {
+					continue
+				}
+				internal, ok := named.Underlying().(*types.Struct)
+				if !ok {
+					continue
+				}
+
+				st := &structure.Structure{
+					ImportPath: pkg.PkgPath,
+					Package:    pkg.Name,
+					Name:       obj.Name(),
+					NoUseQual:  pkg.PkgPath == g.outputImportPath,
+				}
+				g.appendStructure(st, internal, obj)
+			}
 		}
+	}
+	return aliases, nil
+}
 
-		if _, ok := x.Type.(*ast.StructType); ok {
+// createAliasTarget creates the structure of the target of alias, unless it has been created.
+func (g *generator) createAliasTarget(alias *types.TypeName) error {
+	switch typ := types.Unalias(alias.Type()).(type) {
+	case *types.Struct:
+		_, err := g.createAnonymousStruct(typ)
+		return err
+
+	case *types.Named:
+		internal, ok := typ.Underlying().(*types.Struct)
+		if !ok || typ.TypeParams().Len() > typ.TypeArgs().Len() {
+			return nil
+		}
+		if typ.TypeArgs().Len() > 0 {
+			_, err := g.createGenericInstance(typ)
+			return err
+		}
 
-			structName := x.Name.String()
-			if importPath != g.outputImportPath() && !unicode.IsUpper(rune(structName[0])) {
-				return true
+		obj := typ.Obj()
+		if obj.Pkg() == nil || !obj.Exported() && obj.Pkg().Path() != g.outputImportPath {
+			return nil
+		}
+		for _, st := range analyzedStructs {
+			if st.ImportPath == obj.Pkg().Path() && st.Name == obj.Name() {
+				return nil
 			}
-			structNames = append(structNames, structName)
 		}
-		return true
-	})
-
-	structs := make([]*structure.Structure, len(structNames))
-	for i, structName := range structNames {
-		structs[i] = &structure.Structure{
-			ImportPath: importPath,
-			Package:    packageName,
-			Name:       structName,
-			NoUseQual:  g.noUserQualMap[importPath],
-			File:       parseFile,
+		st := &structure.Structure{
+			ImportPath: obj.Pkg().Path(),
+			Package:    obj.Pkg().Name(),
+			Name:       obj.Name(),
+			NoUseQual:  obj.Pkg().Path() == g.outputImportPath,
 		}
+		g.appendStructure(st, internal, obj)
 	}
-	analyzedStructs = append(analyzedStructs, structs...)
-	analyzedMap[parseFile] = true
-
-	g.parseFile2ImportMap[parseFile] = importMap
-	g.parseFile2DotImportMap[parseFile] = dotStructs
 	return nil
 }
 
-func (g *generator) createImportMap(parseFile *ast.File) (map[string]string, []string) {
-
-	importMap := map[string]string{}
-	dotImports := make([]string, 0)
+// appendStructure adds st to the analyzed structures with the directive of obj.
+// obj is nil for anonymous structs. it returns false when the type is ignored or filtered.
+func (g *generator) appendStructure(st *structure.Structure, internal *types.Struct, obj *types.TypeName) bool {
+	d := g.directives[obj]
+	// types of ext are encoded by the registered ext coder
+	if d.ignore || d.ext || obj != nil && !g.targetFilter.matchType(obj.Name()) {
+		return false
+	}
+	st.Layout = d.layout
+	st.Evolvable = d.evolvable || g.evolvable
+	analyzedStructs = append(analyzedStructs, st)
+	g.structTypes[st] = internal
+	g.structDirectives[st] = d
+	if obj != nil && st.NoUseQual && !st.HasTypeCode() {
+		g.structMethods[st] = g.codecMethods(obj)
+	}
+	return true
+}
 
-	for _, imp := range parseFile.Imports {
+func (g *generator) setFieldToStruct(target *structure.Structure) error {
 
-		value := strings.ReplaceAll(imp.Path.Value, "\"", "")
+	candidates, err := g.collectFields(target, g.structTypes[target], "", 0)
+	if err != nil {
+		return err
+	}
 
-		if imp.Name == nil || imp.Name.Name == "" {
-			key := strings.Split(value, "/")
-			importMap[key[len(key)-1]] = value
-		} else if imp.Name.Name == "." {
-			dotImports = append(dotImports, value)
-		} else {
-			key := strings.ReplaceAll(imp.Name.Name, "\"", "")
-			importMap[key] = value
+	// the shallowest field wins like encoding/json. fields at the same depth conflict.
+	minDepth := map[string]int{}
+	count := map[string]int{}
+	for _, c := range candidates {
+		if d, found := minDepth[c.tag]; !found || c.depth < d {
+			minDepth[c.tag] = c.depth
+			count[c.tag] = 1
+		} else if c.depth == d {
+			count[c.tag]++
 		}
 	}
-	return importMap, dotImports
-}
-
-func (g *generator) setFieldToStructs() error {
-	for _, analyzedStruct := range analyzedStructs {
 
-		importMap := g.parseFile2ImportMap[analyzedStruct.File]
-		dotStructs := g.parseFile2DotImportMap[analyzedStruct.File]
-
-		sameHierarchyStructs := map[string]bool{}
-		for _, aast := range analyzedStructs {
-			if analyzedStruct.ImportPath == aast.ImportPath {
-				sameHierarchyStructs[aast.Name] = true
-			}
+	analyzedFields := make([]structure.Field, 0)
+	reasons := make([]string, 0)
+	for _, c := range candidates {
+		if c.depth != minDepth[c.tag] {
+			continue
+		}
+		if count[c.tag] > 1 {
+			return fmt.Errorf("duplicate tags %s.%s %s", target.Package, target.Name, c.tag)
 		}
 
-		err := g.setFieldToStruct(analyzedStruct, importMap, dotStructs, sameHierarchyStructs)
-		if err != nil {
-			return err
+		var node *structure.Node
+		var ok bool
+		var rs []string
+		if c.ext >= 0 {
+			node, ok, rs = g.createExtNode(c.typ, int8(c.ext), nil)
+		} else {
+			node, ok, rs = g.createNodeRecursive(c.typ, nil)
+		}
+		reasons = append(reasons, rs...)
+		if !ok {
+			continue
+		}
+		if c.omitEmpty && !structure.CanOmitEmpty(node) {
+			return fmt.Errorf("%s.%s: omitempty is not supported for %s", target.Package, target.Name, c.name)
 		}
+
+		analyzedFields = append(analyzedFields, structure.Field{
+			Name:      c.name,
+			Tag:       c.tag,
+			Index:     c.index,
+			OmitEmpty: c.omitEmpty,
+			Node:      node,
+		})
+	}
+	if err := setArrayIndexes(analyzedFields); err != nil {
+		return fmt.Errorf("%s.%s: %v", target.Package, target.Name, err)
+	}
+
+	if len(reasons) > 0 {
+		target.CanGen = false
+		target.Reasons = reasons
+		return nil
 	}
+	target.CanGen = true
+	target.Fields = analyzedFields
 	return nil
 }
 
-func (g *generator) setFieldToStruct(target *structure.Structure,
-	importMap map[string]string, dotStructs map[string]*structure.Structure, sameHierarchyStructs map[string]bool,
-) (err error) {
+type fieldCandidate struct {
+	// selector from the top struct, e.g. Inside.Int
+	name  string
+	tag   string
+	depth int
+	// -1 when the slot is not specified
+	index     int
+	omitEmpty bool
+	// -1 when the field is not an ext type
+	ext int
+	typ types.Type
+}
 
-	analyzedFieldMap := map[string]*structure.Node{}
-	ast.Inspect(target.File, func(n ast.Node) bool {
+// collectFields lists fields of internal. fields of inline structs are promoted with deeper depth.
+func (g *generator) collectFields(target *structure.Structure, internal *types.Struct, prefix string, depth int) ([]fieldCandidate, error) {
+	var candidates []fieldCandidate
+	for i := 0; i < internal.NumFields(); i++ {
+		field := internal.Field(i)
 
-		x, ok := n.(*ast.TypeSpec)
-		if !ok {
-			return true
+		tag, err := parseFieldTag(reflect.StructTag(internal.Tag(i)).Get("msgpack"))
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: field %s: %v", target.Package, target.Name, field.Name(), err)
+		}
+		if tag.ignore {
+			continue
 		}
 
-		if st, ok := x.Type.(*ast.StructType); ok {
-			if x.Name.String() != target.Name {
-				return true
+		if field.Embedded() && (tag.inline || g.inline && tag.name == "") {
+			embedded, err := g.inlineStruct(field, tag.inline)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", target.Package, target.Name, err)
 			}
-
-			canGen := true
-			reasons := make([]string, 0)
-			for i, field := range st.Fields.List {
-
-				key := fmt.Sprint(i)
-
-				value, ok, rs := g.createNodeRecursive(field.Type, nil, importMap, dotStructs, sameHierarchyStructs, target.ImportPath, target.Package)
-				canGen = canGen && ok
-				if ok {
-					analyzedFieldMap[key+"@"+x.Name.String()] = value
+			if embedded != nil {
+				if !field.Exported() && field.Pkg().Path() != g.outputImportPath {
+					continue
 				}
-				reasons = append(reasons, rs...)
-			}
-
-			if canGen {
-				target.CanGen = true
-				target.Fields, err = g.createAnalyzedFields(target.Package, target.Name, analyzedFieldMap, g.fileSet, target.File)
+				promoted, err := g.collectFields(target, embedded, prefix+field.Name()+".", depth+1)
 				if err != nil {
-					return false
+					return nil, err
 				}
-			} else {
-				target.CanGen = false
-				target.Reasons = reasons
+				candidates = append(candidates, promoted...)
+				continue
 			}
 		}
-		return true
-	})
-	return
-}
-func (g *generator) createNodeRecursive(expr ast.Expr, parent *structure.Node,
-	importMap map[string]string, dotStructs map[string]*structure.Structure, sameHierarchyStructs map[string]bool,
-	importPath, packageName string) (*structure.Node, bool, []string) {
 
-	reasons := make([]string, 0)
-	if ident, ok := expr.(*ast.Ident); ok {
-		// dot import
-		if dot, found := dotStructs[ident.Name]; found {
-			return structure.CreateStructNode(dot.ImportPath, dot.Name, ident.Name, parent), true, reasons
+		if !field.Exported() {
+			continue
 		}
-		// time
-		if ident.Name == "Time" {
-			return structure.CreateStructNode("time", "time", ident.Name, parent), true, reasons
+
+		name := tag.name
+		if name == "" {
+			name = field.Name()
+			if keyName := g.structDirectives[target].keyName; keyName != nil {
+				name = keyName(name)
+			}
 		}
-		// same hierarchy struct
-		if ident.Obj != nil && ident.Obj.Kind == ast.Typ {
-			return structure.CreateStructNode(importPath, packageName, ident.Name, parent), true, reasons
+		candidates = append(candidates, fieldCandidate{
+			name:      prefix + field.Name(),
+			tag:       name,
+			depth:     depth,
+			index:     tag.index,
+			omitEmpty: tag.omitEmpty,
+			ext:       tag.ext,
+			typ:       field.Type(),
+		})
+	}
+	return candidates, nil
+}
+
+// inlineStruct returns the struct of embedded field to be inlined.
+// it returns nil when the field is inlined by default but can not be.
+func (g *generator) inlineStruct(field *types.Var, explicit bool) (*types.Struct, error) {
+	if _, ok := field.Type().(*types.Pointer); ok {
+		if explicit {
+			return nil, fmt.Errorf("inline embedded pointer %s is not supported", field.Name())
 		}
+		return nil, nil
+	}
 
-		// same hierarchy struct
-		if _, found := sameHierarchyStructs[ident.Name]; found {
-			return structure.CreateStructNode(importPath, packageName, ident.Name, parent), true, reasons
+	named, ok := field.Type().(*types.Named)
+	isTime := ok && named.Obj().Pkg() != nil && named.Obj().Pkg().Path() == "time" && named.Obj().Name() == "Time"
+	internal, isStruct := field.Type().Underlying().(*types.Struct)
+	if isTime || !isStruct {
+		if explicit {
+			return nil, fmt.Errorf("inline field %s is not a struct", field.Name())
 		}
+		return nil, nil
+	}
+	return internal, nil
+}
+
+// fieldTag is the msgpack tag of field. the first element is the name and the others are options.
+type fieldTag struct {
+	name   string
+	ignore bool
+	inline bool
+	// slot in array layout, -1 when not specified
+	index     int
+	omitEmpty bool
+	// ext type from 0 to 127, -1 when not specified
+	ext int
+}
 
-		if structure.IsPrimitive(ident.Name) {
-			return structure.CreateIdentNode(ident, parent), true, reasons
+// maxIndex is the largest slot of the idx tag option, so that slots fit in the array16 format.
+const maxIndex = math.MaxUint16 - 1
+
+func parseFieldTag(tag string) (fieldTag, error) {
+	ft := fieldTag{index: -1, ext: -1}
+	for i, s := range strings.Split(tag, ",") {
+		switch {
+		case s == "ignore" || s == "-":
+			ft.ignore = true
+		case i == 0:
+			ft.name = s
+		case s == "inline":
+			ft.inline = true
+		case s == "omitempty":
+			ft.omitEmpty = true
+		case strings.HasPrefix(s, "idx="):
+			index, err := strconv.Atoi(strings.TrimPrefix(s, "idx="))
+			if err != nil || index < 0 || index > maxIndex {
+				return ft, fmt.Errorf("invalid index %s", s)
+			}
+			ft.index = index
+		case strings.HasPrefix(s, "ext="):
+			ext, err := strconv.ParseInt(strings.TrimPrefix(s, "ext="), 10, 8)
+			if err != nil || ext < 0 {
+				return ft, fmt.Errorf("invalid ext %s", s)
+			}
+			ft.ext = int(ext)
 		}
-		return nil, false, []string{fmt.Sprintf("identifier %s is not suppoted or unknown struct ", ident.Name)}
 	}
+	return ft, nil
+}
 
-	// struct
-	if selector, ok := expr.(*ast.SelectorExpr); ok {
-		pkgName := fmt.Sprint(selector.X)
-		return structure.CreateStructNode(importMap[pkgName], pkgName, selector.Sel.Name, parent), true, reasons
+// setArrayIndexes fixes the slots of fields in array layout.
+// fields without index take free slots from the lowest in order of declaration.
+func setArrayIndexes(fields []structure.Field) error {
+	used := map[int]string{}
+	for _, field := range fields {
+		if field.Index < 0 {
+			continue
+		}
+		if name, found := used[field.Index]; found {
+			return fmt.Errorf("duplicate index %d %s %s", field.Index, name, field.Name)
+		}
+		used[field.Index] = field.Name
 	}
 
-	// slice or array
-	if array, ok := expr.(*ast.ArrayType); ok {
-		var node *structure.Node
-		if array.Len == nil {
-			node = structure.CreateSliceNode(parent)
-		} else {
-			lit := array.Len.(*ast.BasicLit)
-			// parse num
-			n := new(big.Int)
-			if litValue := strings.ToLower(lit.Value); strings.HasPrefix(litValue, "0b") {
-				n.SetString(strings.ReplaceAll(litValue, "0b", ""), 2)
-			} else if strings.HasPrefix(litValue, "0o") {
-				n.SetString(strings.ReplaceAll(litValue, "0o", ""), 8)
-			} else if strings.HasPrefix(litValue, "0x") {
-				n.SetString(strings.ReplaceAll(litValue, "0x", ""), 16)
-			} else {
-				n.SetString(litValue, 10)
+	next := 0
+	for i := range fields {
+		if fields[i].Index >= 0 {
+			continue
+		}
+		for {
+			if _, found := used[next]; !found {
+				break
 			}
-			node = structure.CreateArrayNode(n.Uint64(), parent)
+			next++
+		}
+		fields[i].Index = next
+		used[next] = fields[i].Name
+	}
+	return nil
+}
+
+func (g *generator) createNodeRecursive(t types.Type, parent *structure.Node) (*structure.Node, bool, []string) {
+
+	reasons := make([]string, 0)
+	switch typ := t.(type) {
+	case *types.Basic:
+		if structure.IsPrimitive(typ.Name()) {
+			return structure.CreateIdentNode(typ.Name(), parent), true, reasons
+		}
+		return nil, false, []string{fmt.Sprintf("identifier %s is not suppoted or unknown struct ", typ.Name())}
+
+	case *types.Named:
+		obj := typ.Obj()
+		if obj.Pkg() == nil {
+			// error
+			return nil, false, []string{fmt.Sprintf("identifier %s is not suppoted or unknown struct ", obj.Name())}
 		}
-		key, check, rs := g.createNodeRecursive(array.Elt, node, importMap, dotStructs, sameHierarchyStructs, importPath, packageName)
+		if d := g.directives[obj]; d.ext {
+			return g.createExtNode(typ, d.extCode, parent)
+		}
+		// time
+		if obj.Pkg().Path() == "time" && obj.Name() == "Time" {
+			return structure.CreateStructNode("time", "time", obj.Name(), parent), true, reasons
+		}
+		if _, ok := typ.Underlying().(*types.Struct); ok {
+			if typ.TypeArgs().Len() > 0 {
+				st, err := g.createGenericInstance(typ)
+				if err != nil {
+					return nil, false, []string{err.Error()}
+				}
+				return structure.CreateStructNode(st.ImportPath, st.Package, st.Name, parent), true, reasons
+			}
+			return structure.CreateStructNode(obj.Pkg().Path(), obj.Pkg().Name(), obj.Name(), parent), true, reasons
+		}
+		return g.createNamedNode(typ, parent)
+
+	// slice or array
+	case *types.Slice:
+		node := structure.CreateSliceNode(parent)
+		key, check, rs := g.createNodeRecursive(typ.Elem(), node)
+		node.SetKeyNode(key)
+		reasons = append(reasons, rs...)
+		return node, check, reasons
+
+	case *types.Array:
+		node := structure.CreateArrayNode(uint64(typ.Len()), parent)
+		key, check, rs := g.createNodeRecursive(typ.Elem(), node)
 		node.SetKeyNode(key)
 		reasons = append(reasons, rs...)
 		return node, check, reasons
-	}
 
 	// map
-	if mp, ok := expr.(*ast.MapType); ok {
+	case *types.Map:
 		node := structure.CreateMapNode(parent)
-		key, c1, krs := g.createNodeRecursive(mp.Key, node, importMap, dotStructs, sameHierarchyStructs, importPath, packageName)
-		value, c2, vrs := g.createNodeRecursive(mp.Value, node, importMap, dotStructs, sameHierarchyStructs, importPath, packageName)
+		key, c1, krs := g.createNodeRecursive(typ.Key(), node)
+		value, c2, vrs := g.createNodeRecursive(typ.Elem(), node)
 		node.SetKeyNode(key)
 		node.SetValueNode(value)
 		reasons = append(reasons, krs...)
 		reasons = append(reasons, vrs...)
 		return node, c1 && c2, reasons
-	}
 
 	// *
-	if star, ok := expr.(*ast.StarExpr); ok {
+	case *types.Pointer:
 		node := structure.CreatePointerNode(parent)
-		key, check, rs := g.createNodeRecursive(star.X, node, importMap, dotStructs, sameHierarchyStructs, importPath, packageName)
+		key, check, rs := g.createNodeRecursive(typ.Elem(), node)
 		node.SetKeyNode(key)
 		reasons = append(reasons, rs...)
 		return node, check, reasons
-	}
 
 	// not supported
-	if _, ok := expr.(*ast.InterfaceType); ok {
+	case *types.Interface:
+		if typ.Empty() {
+			return structure.CreateInterfaceNode(parent), true, reasons
+		}
 		return nil, false, []string{"interface type is not supported"}
-	}
-	if _, ok := expr.(*ast.StructType); ok {
-		return nil, false, []string{"inner struct is not supported"}
-	}
-	if _, ok := expr.(*ast.ChanType); ok {
+
+	// alias is the same as its target
+	case *types.Alias:
+		return g.createNodeRecursive(types.Unalias(typ), parent)
+
+	case *types.Struct:
+		st, err := g.createAnonymousStruct(typ)
+		if err != nil {
+			return nil, false, []string{err.Error()}
+		}
+		return structure.CreateStructNode(st.ImportPath, st.Package, st.Name, parent), true, reasons
+	case *types.Chan:
 		return nil, false, []string{"chan type is not supported"}
-	}
-	if _, ok := expr.(*ast.FuncType); ok {
+	case *types.Signature:
 		return nil, false, []string{"func type is not supported"}
 	}
 
-	// unreachable
-	return nil, false, []string{"this field is unknown field"}
+	return nil, false, []string{fmt.Sprintf("type %s is not supported", t.String())}
 }
 
-func (g *generator) createAnalyzedFields(packageName, structName string, analyzedFieldMap map[string]*structure.Node, fset *token.FileSet, file *ast.File) ([]structure.Field, error) {
-
-	// todo : should solve import check, but can not solve now
-	//   can not use importer.Default(). see below https://github.com/golang/go/issues/13847
-	conf := types.Config{
-		Importer: gcexportdata.NewImporter(fset, make(map[string]*types.Package)),
-		// see : https://github.com/golang/lint/blob/master/lint.go#L267
-		Error: func(err error) {},
+// createExtNode creates the node of t encoded as the ext type code. pointers to t are nil or the ext type.
+func (g *generator) createExtNode(t types.Type, code int8, parent *structure.Node) (*structure.Node, bool, []string) {
+	if ptr, ok := types.Unalias(t).(*types.Pointer); ok {
+		node := structure.CreatePointerNode(parent)
+		elem, check, reasons := g.createExtNode(ptr.Elem(), code, node)
+		node.SetKeyNode(elem)
+		return node, check, reasons
 	}
-
-	info := &types.Info{
-		Types:  make(map[ast.Expr]types.TypeAndValue),
-		Defs:   make(map[*ast.Ident]types.Object),
-		Uses:   make(map[*ast.Ident]types.Object),
-		Scopes: make(map[ast.Node]*types.Scope),
+	typeCode, err := g.typeCode(t)
+	if err != nil {
+		return nil, false, []string{err.Error()}
 	}
+	return structure.CreateExtNode(code, typeCode, parent), true, nil
+}
 
-	pkg, _ /*err*/ := conf.Check(packageName, fset, []*ast.File{file}, info)
-	//if err != nil {
-	//	// Consider reporting these errors when golint operates on entire packages
-	//	// https://github.com/golang/lint/blob/master/lint.go#L153
-	//}
-
-	obj := pkg.Scope().Lookup(structName)
-	internal := obj.Type().Underlying().(*types.Struct)
-
-	analyzedFields := make([]structure.Field, 0)
-	tagNameCheck := map[string]bool{}
-	for i := 0; i < internal.NumFields(); i++ {
-		field := internal.Field(i)
-
-		// fmt.Println(field.Id(), field.Type(), field.IsField())
-
-		if field.IsField() && field.Exported() {
-			origin, _ := reflect.StructTag(internal.Tag(i)).Lookup("msgpack")
-			tags := strings.Split(origin, ",")
-
-			name := field.Id()
-			tagName := name
-			ignore := false
-			for _, tag := range tags {
-				if tag == "ignore" || tag == "-" {
-					ignore = true
-				} else if len(tag) > 0 {
-					tagName = tag
-				}
-			}
+// createNamedNode creates the node of the underlying type. it is converted in generated code.
+func (g *generator) createNamedNode(typ *types.Named, parent *structure.Node) (*structure.Node, bool, []string) {
+	obj := typ.Obj()
+	importPath := obj.Pkg().Path()
+	if !obj.Exported() && importPath != g.outputImportPath {
+		return nil, false, []string{fmt.Sprintf("named type %s is not exported", typ.String())}
+	}
+	if _, ok := typ.Underlying().(*types.Pointer); ok {
+		return nil, false, []string{fmt.Sprintf("named pointer type %s is not supported", typ.String())}
+	}
 
-			if ignore {
-				continue
-			}
+	// type Tree map[string]Tree never ends
+	key := typ.String()
+	if g.namedTypes[key] {
+		return nil, false, []string{fmt.Sprintf("recursive named type %s is not supported", key)}
+	}
+	g.namedTypes[key] = true
+	defer delete(g.namedTypes, key)
 
-			if _, found := tagNameCheck[tagName]; found {
-				return nil, fmt.Errorf("duplicate tags %s.%s %s", packageName, structName, tagName)
-			}
-			tagNameCheck[tagName] = true
+	node, ok, reasons := g.createNodeRecursive(typ.Underlying(), parent)
+	if !ok {
+		return nil, false, reasons
+	}
+	node.SetNamedType(importPath, obj.Name(), importPath == g.outputImportPath)
 
-			analyzedFields = append(analyzedFields, structure.Field{
-				Name: name,
-				Tag:  tagName,
-				Node: analyzedFieldMap[fmt.Sprint(i)+"@"+structName],
-			})
+	if typ.TypeArgs().Len() > 0 {
+		typeCode, err := g.typeCode(typ)
+		if err != nil {
+			return nil, false, []string{err.Error()}
 		}
+		node.Named.TypeCode = typeCode
 	}
-
-	return analyzedFields, nil
+	return node, true, reasons
 }
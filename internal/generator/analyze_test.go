@@ -0,0 +1,214 @@
+package generator
+
+import (
+	"go/types"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/shamaton/msgpackgen/msgpack/enc"
+)
+
+func TestInlineDuplicateTags(t *testing.T) {
+	src := `package inline
+
+type A struct{ Int int }
+type B struct{ Int int }
+
+type Shadowed struct {
+	A ` + "`msgpack:\",inline\"`" + `
+	Int int
+}
+
+type Duplicated struct {
+	A
+	B
+}
+`
+	root := newTestModule(t, "example.com/inline", map[string]string{"inline.go": src})
+
+	// A and B are nested objects without inline
+	c := testConfig(root)
+	if err := Run(c); err != nil {
+		t.Fatal(err)
+	}
+
+	// A.Int and B.Int conflict at the same depth
+	c.Inline = true
+	err := Run(c)
+	if err == nil || !strings.Contains(err.Error(), "duplicate tags inline.Duplicated Int") {
+		t.Errorf("duplicate error should occur %v", err)
+	}
+}
+
+func TestArrayIndex(t *testing.T) {
+	root := newTestModule(t, "example.com/index", nil)
+
+	tests := []struct {
+		fields string
+		err    string
+	}{
+		{fields: "A int `msgpack:\",idx=1\"`\n\tB int", err: ""},
+		{fields: "A int `msgpack:\",idx=1\"`\n\tB int `msgpack:\",idx=1\"`", err: "duplicate index 1 A B"},
+		{fields: "A int `msgpack:\",idx=-1\"`", err: "invalid index idx=-1"},
+		{fields: "A int `msgpack:\",idx=x\"`", err: "invalid index idx=x"},
+		{fields: "A int `msgpack:\",idx=65534\"`", err: ""},
+		{fields: "A int `msgpack:\",idx=65535\"`", err: "invalid index idx=65535"},
+	}
+	for _, tt := range tests {
+		src := "package index\n\ntype Index struct {\n\t" + tt.fields + "\n}\n"
+		writeTestFile(t, root, "index.go", src)
+		err := Run(testConfig(root))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: got %v, want %s", tt.fields, err, tt.err)
+		}
+		// gaps are written in loops
+		if code := readTestFile(t, root, "resolver.msgpackgen.go"); err == nil && strings.Count(code, "WriteNil") > 10 {
+			t.Errorf("%s: gaps are unrolled", tt.fields)
+		}
+	}
+}
+
+func TestOmitEmptyUnsupported(t *testing.T) {
+	root := newTestModule(t, "example.com/omit", nil)
+
+	tests := []struct {
+		fields string
+		err    string
+	}{
+		{fields: "P *Inside `msgpack:\",omitempty\"`\n\tT time.Time `msgpack:\",omitempty\"`", err: ""},
+		{fields: "S Inside `msgpack:\",omitempty\"`", err: "omit.Omit: omitempty is not supported for S"},
+		{fields: "E int `msgpack:\",ext=1,omitempty\"`", err: "omit.Omit: omitempty is not supported for E"},
+	}
+	for _, tt := range tests {
+		src := "package omit\n\nimport \"time\"\n\nvar _ time.Time\n\ntype Inside struct{ Int int }\n\ntype Omit struct {\n\t" + tt.fields + "\n}\n"
+		writeTestFile(t, root, "omit.go", src)
+		err := Run(testConfig(root))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: got %v, want %s", tt.fields, err, tt.err)
+		}
+	}
+}
+
+func TestRecursiveNamed(t *testing.T) {
+	src := `package recursive
+
+type Tree map[string]Tree
+type List []List
+type Node struct{ Children map[string][]List }
+
+type A struct{ Tree Tree }
+type B struct{ Lists []List }
+type C struct{ Int int }
+`
+	root := newTestModule(t, "example.com/recursive", map[string]string{"recursive.go": src})
+
+	if err := Run(testConfig(root)); err != nil {
+		t.Fatal(err)
+	}
+	code := readTestFile(t, root, "resolver.msgpackgen.go")
+	for name, want := range map[string]bool{"A": false, "B": false, "Node": false, "C": true} {
+		if got := strings.Contains(code, "reflect.TypeOf((*"+name+")(nil)).Elem()"); got != want {
+			t.Errorf("%s is generated %v, want %v", name, got, want)
+		}
+	}
+
+	g := generator{namedTypes: map[string]bool{}}
+	pkg := types.NewPackage("example.com/recursive", "recursive")
+	tree := types.NewNamed(types.NewTypeName(0, pkg, "Tree", nil), nil, nil)
+	tree.SetUnderlying(types.NewMap(types.Typ[types.String], tree))
+	_, ok, reasons := g.createNodeRecursive(tree, nil)
+	if ok || len(reasons) != 1 || reasons[0] != "recursive named type example.com/recursive.Tree is not supported" {
+		t.Errorf("recursive reason should be returned %v %v", ok, reasons)
+	}
+}
+
+func TestRegisterInit(t *testing.T) {
+	root := newTestModule(t, "example.com/register", map[string]string{
+		"register.go": "package register\n\ntype A struct{ Int int }\n",
+	})
+
+	for _, register := range []bool{false, true} {
+		c := testConfig(root)
+		c.Register = register
+		if err := Run(c); err != nil {
+			t.Fatal(err)
+		}
+		code := readTestFile(t, root, "resolver.msgpackgen.go")
+		if got := strings.Contains(code, "func init() {\n\tRegisterGeneratedResolver()\n}"); got != register {
+			t.Errorf("init is generated %v, want %v", got, register)
+		}
+		if !strings.Contains(code, "reflect.TypeOf((*A)(nil)).Elem()") {
+			t.Error("type A is not registered")
+		}
+	}
+}
+
+func TestSharedTypes(t *testing.T) {
+	code := "type A struct {\n\tV struct{ Int int }\n\tP image.Point\n}\n\ntype Point = image.Point\n"
+	root := newTestModule(t, "example.com/shared", map[string]string{
+		"p1/p1.go": "package p1\n\nimport \"image\"\n\n" + code,
+		"p2/p2.go": "package p2\n\nimport \"image\"\n\n" + code,
+	})
+
+	// types generated by both packages are shared, so that their resolvers are registered together
+	for _, pkg := range []string{"p1", "p2"} {
+		c := testConfig(filepath.Join(root, pkg))
+		if err := Run(c); err != nil {
+			t.Fatal(err)
+		}
+		code := readTestFile(t, root, pkg+"/resolver.msgpackgen.go")
+		// keys of the resolver are sorted
+		_, shared, _ := strings.Cut(code, "SharedTypes:")
+		shared, owned, found := strings.Cut(shared, "Types:")
+		if !found {
+			t.Fatal("types are not registered")
+		}
+		owned = owned[:strings.Index(owned, "}")]
+		if !strings.Contains(owned, "reflect.TypeOf((*A)(nil)).Elem()") {
+			t.Error("type A is not registered")
+		}
+		if strings.Contains(owned, "Int int") || strings.Contains(owned, "image.Point") {
+			t.Error("types declared outside of the package should not be owned", owned)
+		}
+		if !strings.Contains(shared, "Int int") || !strings.Contains(shared, "reflect.TypeOf((*image.Point)(nil)).Elem()") {
+			t.Error("types declared outside of the package should be shared", shared)
+		}
+	}
+}
+
+func TestComplexType(t *testing.T) {
+	root := newTestModule(t, "example.com/complex", map[string]string{
+		"complex.go": "package complex\n\ntype A struct{ C complex64 }\n",
+	})
+
+	// nil of the zero value config is the default type
+	defaultType, otherType := int(enc.DefaultComplexTypeCode), 3
+	for _, complexType := range []*int{nil, &defaultType, &otherType} {
+		c := testConfig(root)
+		c.ComplexType = complexType
+		if err := Run(c); err != nil {
+			t.Fatal(err)
+		}
+		code := readTestFile(t, root, "resolver.msgpackgen.go")
+		// the type is carried by the resolver, instead of the package setting
+		want := complexType == &otherType
+		if got := strings.Contains(code, "var ___complexTypeCode int8 = 3") && strings.Contains(code, "ComplexTypeCode: &___complexTypeCode"); got != want {
+			t.Errorf("complex type is set %v, want %v", got, want)
+		}
+		if strings.Contains(code, "___complexTypeCode") != want {
+			t.Error("ext type of complex is not the default", code)
+		}
+		if strings.Contains(code, "SetComplexTypeCode") {
+			t.Error("the package setting is changed by the generated code")
+		}
+	}
+
+	for _, complexType := range []int{-1, 128, -129} {
+		c := testConfig(root)
+		c.ComplexType = &complexType
+		if err := Run(c); err == nil {
+			t.Errorf("ext type %d should be invalid", complexType)
+		}
+	}
+}
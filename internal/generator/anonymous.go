@@ -0,0 +1,35 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"go/types"
+
+	"github.com/shamaton/msgpackgen/internal/generator/structure"
+)
+
+// createAnonymousStruct returns the structure of an anonymous struct type.
+// identical types share the same structure.
+func (g *generator) createAnonymousStruct(typ *types.Struct) (*structure.Structure, error) {
+	key := typeKey(typ)
+	if st, ok := g.typeCodeStructs[key]; ok {
+		return st, nil
+	}
+
+	typeCode, err := g.typeCode(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &structure.Structure{
+		ImportPath: g.outputImportPath,
+		Package:    g.outputPackageName,
+		Name:       fmt.Sprintf("Anonymous%x", sha256.Sum256([]byte(key)))[:len("Anonymous")+16],
+		NoUseQual:  true,
+		TypeCode:   typeCode,
+		TypeString: types.TypeString(typ, g.qualifier),
+	}
+	g.typeCodeStructs[key] = st
+	g.appendStructure(st, typ, nil)
+	return st, nil
+}
@@ -0,0 +1,163 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/shamaton/msgpackgen/internal/generator/structure"
+	"golang.org/x/tools/go/packages"
+)
+
+const directivePrefix = "//msgpackgen:"
+
+// directive is written in the doc comment of a type declaration.
+//
+//	//msgpackgen:ignore      the type is not generated
+//	//msgpackgen:array       the type is always encoded as array
+//	//msgpackgen:map         the type is always encoded as map
+//	//msgpackgen:key snake   keys of fields without tag name are snake, kebab, camel or lower case
+//	//msgpackgen:evolvable   arrays shorter or longer than the fields are accepted on decode
+//	//msgpackgen:ext 5       the type is encoded as ext type 5 by the coder registered with msgpack.RegisterExt
+type directive struct {
+	ignore    bool
+	layout    structure.Layout
+	keyName   func(string) string
+	evolvable bool
+	ext       bool
+	extCode   int8
+}
+
+var keyNamings = map[string]func(string) string{
+	"snake": func(name string) string { return strings.Join(lowerWords(name), "_") },
+	"kebab": func(name string) string { return strings.Join(lowerWords(name), "-") },
+	"camel": func(name string) string {
+		words := lowerWords(name)
+		for i := 1; i < len(words); i++ {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+		return strings.Join(words, "")
+	},
+	"lower": strings.ToLower,
+}
+
+// readDirectives reads directives of types declared in pkg.
+func (g *generator) readDirectives(pkg *packages.Package) error {
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.TYPE {
+				continue
+			}
+
+			for _, spec := range genDecl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				doc := typeSpec.Doc
+				// the doc of "type X struct{}" belongs to the declaration
+				if doc == nil && !genDecl.Lparen.IsValid() {
+					doc = genDecl.Doc
+				}
+				if doc == nil {
+					continue
+				}
+
+				obj, ok := pkg.TypesInfo.Defs[typeSpec.Name].(*types.TypeName)
+				if !ok {
+					continue
+				}
+				d, found, err := parseDirective(doc)
+				if err != nil {
+					return fmt.Errorf("%s.%s: %v", pkg.PkgPath, obj.Name(), err)
+				}
+				if found {
+					g.directives[obj] = d
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func parseDirective(doc *ast.CommentGroup) (directive, bool, error) {
+	var d directive
+	found := false
+	for _, c := range doc.List {
+		if !strings.HasPrefix(c.Text, directivePrefix) {
+			continue
+		}
+		found = true
+
+		args := strings.Fields(strings.TrimPrefix(c.Text, directivePrefix))
+		if len(args) < 1 {
+			return d, false, fmt.Errorf("empty directive")
+		}
+		switch name, params := args[0], args[1:]; {
+		case name == "ignore" && len(params) == 0:
+			d.ignore = true
+
+		case name == "evolvable" && len(params) == 0:
+			d.evolvable = true
+
+		case (name == "array" || name == "map") && len(params) == 0:
+			layout := structure.LayoutArray
+			if name == "map" {
+				layout = structure.LayoutMap
+			}
+			if d.layout != structure.LayoutDefault && d.layout != layout {
+				return d, false, fmt.Errorf("directives array and map conflict")
+			}
+			d.layout = layout
+
+		case name == "ext" && len(params) == 1:
+			code, err := strconv.ParseInt(params[0], 10, 8)
+			if err != nil || code < 0 {
+				return d, false, fmt.Errorf("invalid ext type %s", params[0])
+			}
+			d.ext, d.extCode = true, int8(code)
+
+		case name == "key" && len(params) == 1:
+			keyName, ok := keyNamings[params[0]]
+			if !ok {
+				return d, false, fmt.Errorf("unknown key naming %s", params[0])
+			}
+			d.keyName = keyName
+
+		default:
+			return d, false, fmt.Errorf("unknown directive %s", c.Text)
+		}
+	}
+	return d, found, nil
+}
+
+// lowerWords splits name into lower case words. acronyms are kept in one word, e.g. HTTPServerID is http, server and id.
+func lowerWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		next := cur
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		boundary := unicode.IsUpper(cur) && (!unicode.IsUpper(prev) || unicode.IsLower(next)) ||
+			cur == '_' || prev == '_'
+		if boundary {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	words = append(words, string(runes[start:]))
+
+	lowers := make([]string, 0, len(words))
+	for _, w := range words {
+		if w != "_" && w != "" {
+			lowers = append(lowers, strings.ToLower(w))
+		}
+	}
+	return lowers
+}
@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"go/ast"
+	"testing"
+)
+
+func TestKeyNamings(t *testing.T) {
+	tests := []struct {
+		naming string
+		name   string
+		want   string
+	}{
+		{naming: "snake", name: "HTTPServerID", want: "http_server_id"},
+		{naming: "snake", name: "Int", want: "int"},
+		{naming: "snake", name: "Value_2", want: "value_2"},
+		{naming: "kebab", name: "UserName", want: "user-name"},
+		{naming: "camel", name: "UserID", want: "userId"},
+		{naming: "camel", name: "URL", want: "url"},
+		{naming: "lower", name: "UserID", want: "userid"},
+	}
+	for _, tt := range tests {
+		if got := keyNamings[tt.naming](tt.name); got != tt.want {
+			t.Errorf("%s %s: got %s, want %s", tt.naming, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseDirective(t *testing.T) {
+	doc := func(lines ...string) *ast.CommentGroup {
+		cg := &ast.CommentGroup{}
+		for _, l := range lines {
+			cg.List = append(cg.List, &ast.Comment{Text: l})
+		}
+		return cg
+	}
+
+	if _, found, err := parseDirective(doc("// msgpackgen:ignore is not a directive")); found || err != nil {
+		t.Error("comment is read as directive", found, err)
+	}
+
+	d, found, err := parseDirective(doc("// Doc.", "//msgpackgen:ignore", "//msgpackgen:key snake", "//msgpackgen:evolvable"))
+	if !found || err != nil || !d.ignore || d.keyName == nil || !d.evolvable {
+		t.Error("directives are not read", d, found, err)
+	}
+
+	d, found, err = parseDirective(doc("//msgpackgen:ext 5"))
+	if !found || err != nil || !d.ext || d.extCode != 5 {
+		t.Error("ext directive is not read", d, found, err)
+	}
+
+	for _, bad := range []string{"//msgpackgen:", "//msgpackgen:unknown", "//msgpackgen:key pascal", "//msgpackgen:ignore all", "//msgpackgen:ext -1", "//msgpackgen:ext 128"} {
+		if _, _, err := parseDirective(doc(bad)); err == nil {
+			t.Errorf("%s is accepted", bad)
+		}
+	}
+	if _, _, err := parseDirective(doc("//msgpackgen:array", "//msgpackgen:map")); err == nil {
+		t.Error("conflicting layouts are accepted")
+	}
+}
@@ -0,0 +1,65 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Filter narrows down the structs to be generated.
+// patterns are regular expressions and empty patterns are not used.
+type Filter struct {
+	// matched with import paths of packages
+	IncludePackage string
+	ExcludePackage string
+	// matched with type names, e.g. User for both User and Page[User]
+	IncludeType string
+	ExcludeType string
+	// only files in the input directory are read
+	NonRecursive bool
+}
+
+type targetFilter struct {
+	includePackage, excludePackage *regexp.Regexp
+	includeType, excludeType       *regexp.Regexp
+	nonRecursive                   bool
+}
+
+func (f Filter) compile() (targetFilter, error) {
+	compiled := targetFilter{nonRecursive: f.NonRecursive}
+	patterns := []struct {
+		name    string
+		pattern string
+		dst     **regexp.Regexp
+	}{
+		{"include package", f.IncludePackage, &compiled.includePackage},
+		{"exclude package", f.ExcludePackage, &compiled.excludePackage},
+		{"include type", f.IncludeType, &compiled.includeType},
+		{"exclude type", f.ExcludeType, &compiled.excludeType},
+	}
+	for _, p := range patterns {
+		if p.pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(p.pattern)
+		if err != nil {
+			return targetFilter{}, fmt.Errorf("invalid %s pattern: %v", p.name, err)
+		}
+		*p.dst = re
+	}
+	return compiled, nil
+}
+
+func (f targetFilter) matchPackage(importPath string) bool {
+	return match(f.includePackage, f.excludePackage, importPath)
+}
+
+func (f targetFilter) matchType(name string) bool {
+	return match(f.includeType, f.excludeType, name)
+}
+
+func match(include, exclude *regexp.Regexp, s string) bool {
+	if include != nil && !include.MatchString(s) {
+		return false
+	}
+	return exclude == nil || !exclude.MatchString(s)
+}
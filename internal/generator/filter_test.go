@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilter(t *testing.T) {
+	root := newTestModule(t, "example.com/filter", map[string]string{
+		"a.go":          "package filter\n\ntype User struct{ Int int }\ntype UserList struct{ Int int }\ntype Item struct{ Int int }\n",
+		"sub/b.go":      "package sub\n\ntype Sub struct{ Int int }\n",
+		"internal/c.go": "package internal\n\ntype Hidden struct{ Int int }\n",
+	})
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "all", filter: Filter{}, want: []string{"User", "UserList", "Item", "sub.Sub", "internal.Hidden"}},
+		{name: "non recursive", filter: Filter{NonRecursive: true}, want: []string{"User", "UserList", "Item"}},
+		{name: "include package", filter: Filter{IncludePackage: `/sub$`}, want: []string{"sub.Sub"}},
+		{name: "exclude package", filter: Filter{ExcludePackage: `/internal`}, want: []string{"User", "UserList", "Item", "sub.Sub"}},
+		{name: "include type", filter: Filter{IncludeType: `^User`}, want: []string{"User", "UserList"}},
+		{name: "exclude type", filter: Filter{ExcludeType: `List$|^Hidden$`}, want: []string{"User", "Item", "sub.Sub"}},
+	}
+	all := tests[0].want
+	for _, tt := range tests {
+		c := testConfig(root)
+		c.Filter = tt.filter
+		if err := Run(c); err != nil {
+			t.Fatal(tt.name, err)
+		}
+		code := readTestFile(t, root, "resolver.msgpackgen.go")
+
+		for _, name := range all {
+			want := false
+			for _, w := range tt.want {
+				want = want || w == name
+			}
+			if got := strings.Contains(code, "case "+name+":"); got != want {
+				t.Errorf("%s: %s is generated %v, want %v", tt.name, name, got, want)
+			}
+		}
+	}
+
+	c := testConfig(root)
+	c.Filter = Filter{IncludeType: "("}
+	if err := Run(c); err == nil {
+		t.Error("invalid pattern should be error")
+	}
+	c.Filter = Filter{IncludePackage: "nothing"}
+	if err := Run(c); err == nil {
+		t.Error("no package should be error")
+	}
+}
@@ -2,103 +2,141 @@ package generator
 
 import (
 	"fmt"
-	"go/ast"
 	"go/token"
+	"go/types"
 	"io/ioutil"
+	"math"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 
 	. "github.com/dave/jennifer/jen"
 	"github.com/shamaton/msgpackgen/internal/generator/ptn"
 	"github.com/shamaton/msgpackgen/internal/generator/structure"
+	"github.com/shamaton/msgpackgen/msgpack/enc"
+	"golang.org/x/tools/go/packages"
 )
 
 var analyzedStructs []*structure.Structure
 
-// todo : complexのext値を変更できるようにする
 // todo : define error (xerrors?)
 
 type generator struct {
-	fileSet               *token.FileSet
-	targetPackages        map[string]bool
-	parseFiles            []*ast.File
-	importPath2ParseFiles map[string][]*ast.File
-	parseFile2ImportPath  map[*ast.File]string
-	importPath2package    map[string]string
-	noUserQualMap         map[string]bool
-
-	parseFile2ImportMap    map[*ast.File]map[string]string
-	parseFile2DotImportMap map[*ast.File]map[string]*structure.Structure
-
-	outputDir           string
-	outputPackageName   string
-	outputPackagePrefix string
-
-	pointer int
-	verbose bool
-	strict  bool
+	packages    []*packages.Package
+	structTypes map[*structure.Structure]*types.Struct
+	// anonymous structs and instantiated generic structs by type string
+	typeCodeStructs map[string]*structure.Structure
+	// directives by type, and by structure created from the type
+	directives       map[*types.TypeName]directive
+	structDirectives map[*structure.Structure]directive
+	// kinds of codec methods generated on types in the output package
+	structMethods map[*structure.Structure][]string
+	// named non-struct types being analyzed, to detect recursive definitions
+	namedTypes map[string]bool
+
+	outputDir         string
+	outputFile        string
+	outputFset        *token.FileSet
+	outputPackageName string
+	outputImportPath  string
+
+	modules *moduleResolver
+
+	pointer      int
+	verbose      bool
+	strict       bool
+	inline       bool
+	evolvable    bool
+	register     bool
+	complexType  int8
+	targetFilter targetFilter
 }
 
-func (g *generator) outputImportPath() string {
-	return fmt.Sprintf("%s/%s", g.outputPackagePrefix, g.outputPackageName)
+// Config is the settings of Run.
+type Config struct {
+	// directory of source files, and of the generated file. Output is Input when empty.
+	Input    string
+	Output   string
+	FileName string
+	// pointer level to consider
+	Pointer int
+	// types not generated are errors in the generated resolver, instead of being processed by shamaton/msgpack
+	Strict  bool
+	Verbose bool
+	// embedded structs without msgpack tag name are inlined
+	Inline bool
+	// arrays shorter or longer than struct fields are accepted on decode
+	Evolvable bool
+	// the generated resolver is registered in init
+	Register bool
+	// ext type of complex64 and complex128. nil is enc.DefaultComplexTypeCode.
+	ComplexType *int
+	Filter      Filter
 }
 
-func Run(input, out, fileName string, pointer int, strict, verbose bool) error {
+func Run(c Config) error {
 
-	_, err := os.Stat(input)
+	_, err := os.Stat(c.Input)
 	if err != nil {
 		return err
 	}
 
+	out := c.Output
 	if out == "" {
-		out = input
+		out = c.Input
 	}
 
+	pointer := c.Pointer
 	if pointer < 0 {
 		pointer = 1
 	}
 
+	// -1 is the timestamp
+	complexType := int(enc.DefaultComplexTypeCode)
+	if c.ComplexType != nil {
+		complexType = *c.ComplexType
+	}
+	if complexType < math.MinInt8 || complexType > math.MaxInt8 || complexType == -1 {
+		return fmt.Errorf("invalid ext type of complex %d", complexType)
+	}
+
+	compiled, err := c.Filter.compile()
+	if err != nil {
+		return err
+	}
+
 	g := generator{
-		pointer:               pointer,
-		strict:                strict,
-		verbose:               verbose,
-		targetPackages:        map[string]bool{},
-		parseFiles:            []*ast.File{},
-		importPath2package:    map[string]string{},
-		importPath2ParseFiles: map[string][]*ast.File{},
-		parseFile2ImportPath:  map[*ast.File]string{},
-		noUserQualMap:         map[string]bool{},
-
-		parseFile2ImportMap:    map[*ast.File]map[string]string{},
-		parseFile2DotImportMap: map[*ast.File]map[string]*structure.Structure{},
-	}
-	return g.run(input, out, fileName)
+		pointer:          pointer,
+		strict:           c.Strict,
+		verbose:          c.Verbose,
+		inline:           c.Inline,
+		evolvable:        c.Evolvable,
+		register:         c.Register,
+		complexType:      int8(complexType),
+		targetFilter:     compiled,
+		structTypes:      map[*structure.Structure]*types.Struct{},
+		typeCodeStructs:  map[string]*structure.Structure{},
+		directives:       map[*types.TypeName]directive{},
+		structDirectives: map[*structure.Structure]directive{},
+		structMethods:    map[*structure.Structure][]string{},
+		namedTypes:       map[string]bool{},
+	}
+	return g.run(c.Input, out, c.FileName)
 }
 
-func getImportPath(path string) (string, error) {
-	goPathAll := os.Getenv("GOPATH")
-	sep := ":"
-	if runtime.GOOS == "windows" {
-		sep = ";"
+func (g *generator) getImportPath(path string) (string, error) {
+	importPath, ok, err := g.modules.importPath(path)
+	if err != nil {
+		return "", err
 	}
-	goPaths := strings.Split(goPathAll, sep)
-
-	for _, goPath := range goPaths {
-		if !strings.HasPrefix(path, goPath) {
-			continue
-		}
-		paths := strings.SplitN(filepath.ToSlash(path), filepath.ToSlash(goPath)+"/src/", 2)
-		if len(paths) != 2 {
-			return "", fmt.Errorf("%s get import path failed", path)
-		}
-		return paths[1], nil
+	if ok {
+		return importPath, nil
 	}
-	return "", fmt.Errorf("path %s is outside goppath", path)
+	return getGoPathImportPath(path)
 }
 
 func (g *generator) run(input, out, fileName string) error {
+	analyzedStructs = nil
 
 	outAbs, err := filepath.Abs(out)
 	if err != nil {
@@ -106,11 +144,14 @@ func (g *generator) run(input, out, fileName string) error {
 	}
 
 	g.outputDir = outAbs
-	importPath, err := getImportPath(g.outputDir)
+	g.modules, err = newModuleResolver(g.outputDir)
+	if err != nil {
+		return err
+	}
+	g.outputImportPath, err = g.getImportPath(g.outputDir)
 	if err != nil {
 		return err
 	}
-	g.outputPackageName = importPath
 
 	filePaths, err := g.getTargetFiles(input)
 	if err != nil {
@@ -120,7 +161,33 @@ func (g *generator) run(input, out, fileName string) error {
 		return fmt.Errorf("not found go File")
 	}
 
-	err = g.getPackages(filePaths)
+	var dirs []string
+	dirCheck := map[string]bool{}
+	for _, path := range filePaths {
+		dir := filepath.Dir(path)
+		if dirCheck[dir] {
+			continue
+		}
+		dirCheck[dir] = true
+
+		importPath, err := g.getImportPath(dir)
+		if err != nil {
+			return err
+		}
+		if !g.targetFilter.matchPackage(importPath) {
+			if g.verbose {
+				fmt.Println("skipping filtered package ", importPath)
+			}
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	if len(dirs) < 1 {
+		return fmt.Errorf("not found package matched with the filter")
+	}
+
+	g.outputFile = filepath.Join(g.outputDir, fileName)
+	err = g.getPackages(dirs, g.outputFile)
 	if err != nil {
 		return err
 	}
@@ -136,7 +203,11 @@ func (g *generator) run(input, out, fileName string) error {
 	if g.verbose {
 		fmt.Println("=========== generated ==========")
 		for _, v := range analyzedStructs {
-			fmt.Println(v.ImportPath, v.Name)
+			if v.HasTypeCode() {
+				fmt.Println(v.ImportPath, v.TypeString)
+			} else {
+				fmt.Println(v.ImportPath, v.Name)
+			}
 		}
 		fmt.Println("=========== not generated ==========")
 		for _, s := range reasons {
@@ -162,6 +233,9 @@ func (g *generator) getTargetFiles(dir string) ([]string, error) {
 	var paths []string
 	for _, file := range files {
 		if file.IsDir() {
+			if g.targetFilter.nonRecursive {
+				continue
+			}
 			path, err := g.getTargetFiles(filepath.Join(dir, file.Name()))
 			if err != nil {
 				return nil, err
@@ -232,19 +306,32 @@ func (g *generator) setOthers() {
 
 func (g *generator) generateCode() *File {
 
-	f := NewFilePath(g.outputImportPath())
+	var f *File
+	if g.outputPackageName != "" {
+		f = NewFilePathName(g.outputImportPath, g.outputPackageName)
+	} else {
+		f = NewFilePath(g.outputImportPath)
+	}
 
 	registerName := "RegisterGeneratedResolver"
 	f.HeaderComment("// Code generated by msgpackgen. DO NOT EDIT.")
-	f.Comment(fmt.Sprintf("// %s registers generated resolver.\n", registerName)).
+	f.Comment(fmt.Sprintf("// %s registers generated resolver.\n// it panics when a type is registered by another resolver.\n", registerName)).
 		Func().Id(registerName).Params().Block(
-		Qual(ptn.PkTop, "SetResolver").Call(
-			Id(ptn.PrivateFuncName("encodeAsMap")),
-			Id(ptn.PrivateFuncName("encodeAsArray")),
-			Id(ptn.PrivateFuncName("decodeAsMap")),
-			Id(ptn.PrivateFuncName("decodeAsArray")),
+		If(Err().Op(":=").Qual(ptn.PkTop, "Register").Call(Id(ptn.PrivateFuncName("resolver"))), Err().Op("!=").Nil()).Block(
+			Panic(Err()),
 		),
 	)
+	// the resolver is shared with the methods generated on types
+	f.Var().Id(ptn.PrivateFuncName("resolver")).Op("=").Add(g.resolverCode())
+	if g.complexType != enc.DefaultComplexTypeCode {
+		f.Comment("// ext type of complex64 and complex128 of the resolver\n")
+		f.Var().Id(ptn.PrivateFuncName("complexTypeCode")).Int8().Op("=").Lit(int(g.complexType))
+	}
+	if g.register {
+		f.Func().Id("init").Params().Block(
+			Id(registerName).Call(),
+		)
+	}
 
 	encReturn := Return(Nil(), Nil())
 	decReturn := Return(False(), Nil())
@@ -284,35 +371,100 @@ func (g *generator) generateCode() *File {
 		)
 	}
 
+	// the layout of the methods generated on types is chosen by the package settings
 	g.encodeTopTemplate("encode", f).Block(
-		If(Qual(ptn.PkTop, "StructAsArray").Call()).Block(
-			Return(Id(ptn.PrivateFuncName("encodeAsArray")).Call(Id("i"))),
-		).Else().Block(
-			Return(Id(ptn.PrivateFuncName("encodeAsMap")).Call(Id("i"))),
-		),
+		Return(Qual(ptn.PkTop, "EncodeWithResolver").Call(Id("i"), Op("&").Id(ptn.PrivateFuncName("resolver")))),
 	)
 
-	g.encodeTopTemplate("encodeAsArray", f).Block(encodeAsArrayCode...)
-	g.encodeTopTemplate("encodeAsMap", f).Block(encodeAsMapCode...)
+	// encoding to a new slice is appending to nil
+	g.encodeTopTemplate("encodeAsArray", f).Block(
+		Return(Id(ptn.PrivateFuncName("appendAsArray")).Call(Qual(ptn.PkEnc, "NewEncoder").Call(), Nil(), Id("i"))),
+	)
+	g.encodeTopTemplate("encodeAsMap", f).Block(
+		Return(Id(ptn.PrivateFuncName("appendAsMap")).Call(Qual(ptn.PkEnc, "NewEncoder").Call(), Nil(), Id("i"))),
+	)
 
-	g.decodeTopTemplate("decode", f).Block(
-		If(Qual(ptn.PkTop, "StructAsArray").Call()).Block(
-			Return(Id(ptn.PrivateFuncName("decodeAsArray")).Call(Id("data"), Id("i"))),
-		).Else().Block(
-			Return(Id(ptn.PrivateFuncName("decodeAsMap")).Call(Id("data"), Id("i"))),
-		),
+	// encoders are created with the options of the call
+	g.appendTopTemplate("appendAsArray", f).Block(encodeAsArrayCode...)
+	g.appendTopTemplate("appendAsMap", f).Block(encodeAsMapCode...)
+
+	f.Comment("// decode\n").
+		Func().Id(ptn.PrivateFuncName("decode")).Params(Id("data").Index().Byte(), Id("i").Interface()).Error().Block(
+		Return(Qual(ptn.PkTop, "DecodeWithResolver").Call(Id("data"), Id("i"), Op("&").Id(ptn.PrivateFuncName("resolver")))),
 	)
 
-	g.decodeTopTemplate("decodeAsArray", f).Block(decodeAsArrayCode...)
-	g.decodeTopTemplate("decodeAsMap", f).Block(decodeAsMapCode...)
+	// decoders are created with the options of the call
+	g.decoderTopTemplate("decodeAsArray", f).Block(decodeAsArrayCode...)
+	g.decoderTopTemplate("decodeAsMap", f).Block(decodeAsMapCode...)
 
 	for _, st := range analyzedStructs {
 		st.CreateCode(f)
+		g.createMethodCode(f, st)
 	}
 
 	return f
 }
 
+// resolverCode creates the resolver of the generated types named by the output package.
+func (g *generator) resolverCode() *Statement {
+	// types declared by the input packages are owned by the resolver. the others may be
+	// generated in other packages too, so that they are shared with their resolvers.
+	var typeCodes, sharedCodes []Code
+	for _, v := range analyzedStructs {
+		code := Qual("reflect", "TypeOf").Call(Parens(Op("*").Add(g.structTypeCode(v))).Call(Nil())).Dot("Elem").Call()
+		if g.owned(v) {
+			typeCodes = append(typeCodes, code)
+		} else {
+			sharedCodes = append(sharedCodes, code)
+		}
+	}
+	var packageCodes []Code
+	for _, pkg := range g.packages {
+		packageCodes = append(packageCodes, Lit(pkg.PkgPath))
+	}
+	list := Options{Open: "{", Close: "}", Separator: ",", Multi: true}
+	fields := Dict{
+		Id("Name"):          Lit(g.outputImportPath),
+		Id("Types"):         Index().Qual("reflect", "Type").Custom(list, typeCodes...),
+		Id("SharedTypes"):   Index().Qual("reflect", "Type").Custom(list, sharedCodes...),
+		Id("Packages"):      Index().String().Custom(list, packageCodes...),
+		Id("Strict"):        Lit(g.strict),
+		Id("EncodeAsMap"):   Id(ptn.PrivateFuncName("encodeAsMap")),
+		Id("EncodeAsArray"): Id(ptn.PrivateFuncName("encodeAsArray")),
+		Id("DecodeAsMap"):   Id(ptn.PrivateFuncName("decodeAsMap")),
+		Id("DecodeAsArray"): Id(ptn.PrivateFuncName("decodeAsArray")),
+		Id("AppendAsMap"):   Id(ptn.PrivateFuncName("appendAsMap")),
+		Id("AppendAsArray"): Id(ptn.PrivateFuncName("appendAsArray")),
+	}
+	if g.complexType != enc.DefaultComplexTypeCode {
+		fields[Id("ComplexTypeCode")] = Op("&").Id(ptn.PrivateFuncName("complexTypeCode"))
+	}
+	return Qual(ptn.PkTop, "Resolver").Values(fields)
+}
+
+// owned reports whether v is a named type declared by one of the input packages.
+func (g *generator) owned(v *structure.Structure) bool {
+	if v.HasTypeCode() {
+		return false
+	}
+	for _, pkg := range g.packages {
+		if pkg.PkgPath == v.ImportPath {
+			return true
+		}
+	}
+	return false
+}
+
+// structTypeCode refers to the type of v from the output package.
+func (g *generator) structTypeCode(v *structure.Structure) *Statement {
+	if v.HasTypeCode() {
+		return Add(v.TypeCode)
+	} else if v.NoUseQual {
+		return Id(v.Name)
+	}
+	return Qual(v.ImportPath, v.Name)
+}
+
 func (g *generator) output(f *File, genFileName string) error {
 
 	if err := os.MkdirAll(g.outputDir, 0777); err != nil {
@@ -344,9 +496,9 @@ func (g *generator) output(f *File, genFileName string) error {
 	return err
 }
 
-func (g *generator) decodeTopTemplate(name string, f *File) *Statement {
+func (g *generator) decoderTopTemplate(name string, f *File) *Statement {
 	return f.Comment(fmt.Sprintf("// %s\n", name)).
-		Func().Id(ptn.PrivateFuncName(name)).Params(Id("data").Index().Byte(), Id("i").Interface()).Params(Bool(), Error())
+		Func().Id(ptn.PrivateFuncName(name)).Params(Id(ptn.IdDecoder).Op("*").Qual(ptn.PkDec, "Decoder"), Id("i").Interface()).Params(Bool(), Error())
 }
 
 func (g *generator) encodeTopTemplate(name string, f *File) *Statement {
@@ -354,6 +506,11 @@ func (g *generator) encodeTopTemplate(name string, f *File) *Statement {
 		Func().Id(ptn.PrivateFuncName(name)).Params(Id("i").Interface()).Params(Index().Byte(), Error())
 }
 
+func (g *generator) appendTopTemplate(name string, f *File) *Statement {
+	return f.Comment(fmt.Sprintf("// %s\n", name)).
+		Func().Id(ptn.PrivateFuncName(name)).Params(Id(ptn.IdEncoder).Op("*").Qual(ptn.PkEnc, "Encoder"), Id("dst").Index().Byte(), Id("i").Interface()).Params(Index().Byte(), Error())
+}
+
 func (g *generator) encodeAsArrayCases() []Code {
 	var states, pointers []Code
 	for _, v := range analyzedStructs {
@@ -376,13 +533,13 @@ func (g *generator) encodeAsMapCases() []Code {
 
 func (g *generator) encodeCaseCode(v *structure.Structure, asArray bool) (states []Code, pointers []Code) {
 
-	var caseStatement func(string) *Statement
+	caseStatement := func(op string) *Statement { return Op(op).Add(g.structTypeCode(v)) }
 	var errID *Statement
-	if v.NoUseQual {
-		caseStatement = func(op string) *Statement { return Op(op).Id(v.Name) }
+	if v.HasTypeCode() {
+		errID = Lit(v.TypeString)
+	} else if v.NoUseQual {
 		errID = Lit(v.Name)
 	} else {
-		caseStatement = func(op string) *Statement { return Op(op).Qual(v.ImportPath, v.Name) }
 		errID = Lit(v.ImportPath + "." + v.Name)
 	}
 
@@ -390,27 +547,28 @@ func (g *generator) encodeCaseCode(v *structure.Structure, asArray bool) (states
 	if asArray {
 		calcFuncName = v.CalcArraySizeFuncName()
 		encodeFuncName = v.EncodeArrayFuncName()
-		pointerFuncName = "encodeAsArray"
+		pointerFuncName = "appendAsArray"
 	} else {
 		calcFuncName = v.CalcMapSizeFuncName()
 		encodeFuncName = v.EncodeMapFuncName()
-		pointerFuncName = "encodeAsMap"
+		pointerFuncName = "appendAsMap"
 	}
 
 	f := func(ptr string) *Statement {
 		return Case(caseStatement(ptr)).Block(
-			Id(ptn.IdEncoder).Op(":=").Qual(ptn.PkEnc, "NewEncoder").Call(),
 			List(Id("size"), Err()).Op(":=").Id(calcFuncName).Call(Id(ptr+"v"), Id(ptn.IdEncoder)),
 			If(Err().Op("!=").Nil()).Block(
 				Return(Nil(), Err()),
 			),
-			Id(ptn.IdEncoder).Dot("MakeBytes").Call(Id("size")),
-			List(Id("b"), Id("offset"), Err()).Op(":=").Id(encodeFuncName).Call(Id(ptr+"v"), Id(ptn.IdEncoder), Lit(0)),
+			Id(ptn.IdEncoder).Dot("AppendBytes").Call(Id("dst"), Id("size")),
+			List(Id("b"), Id("offset"), Err()).Op(":=").Id(encodeFuncName).Call(Id(ptr+"v"), Id(ptn.IdEncoder), Len(Id("dst"))),
 			If(Err().Op("!=").Nil()).Block(
+				Id(ptn.IdEncoder).Dot("ReleaseBytes").Call(),
 				Return(Nil(), Err()),
 			),
-			If(Id("size").Op("!=").Id("offset")).Block(
-				Return(Nil(), Qual("fmt", "Errorf").Call(Lit("%s size / offset different %d : %d"), errID, Id("size"), Id("offset"))),
+			If(Id("size").Op("!=").Id("offset").Op("-").Len(Id("dst"))).Block(
+				Id(ptn.IdEncoder).Dot("ReleaseBytes").Call(),
+				Return(Nil(), Qual("fmt", "Errorf").Call(Lit("%s size / offset different %d : %d"), errID, Id("size"), Id("offset").Op("-").Len(Id("dst")))),
 			),
 			Return(Id("b"), Err()),
 		)
@@ -425,7 +583,7 @@ func (g *generator) encodeCaseCode(v *structure.Structure, asArray bool) (states
 	for i := 0; i < g.pointer-1; i++ {
 		ptr := strings.Repeat("*", i+2)
 		pointers = append(pointers, Case(caseStatement(ptr)).Block(
-			Return(Id(ptn.PrivateFuncName(pointerFuncName)).Call(Id("*v"))),
+			Return(Id(ptn.PrivateFuncName(pointerFuncName)).Call(Id(ptn.IdEncoder), Id("dst"), Id("*v"))),
 		))
 	}
 	return
@@ -454,7 +612,9 @@ func (g *generator) decodeAsMapCases() []Code {
 func (g *generator) decodeCaseCode(v *structure.Structure, asArray bool) (states []Code, pointers []Code) {
 
 	var caseStatement func(string) *Statement
-	if v.NoUseQual {
+	if v.HasTypeCode() {
+		caseStatement = func(op string) *Statement { return Op(op).Add(v.TypeCode) }
+	} else if v.NoUseQual {
 		caseStatement = func(op string) *Statement { return Op(op).Id(v.Name) }
 	} else {
 		caseStatement = func(op string) *Statement { return Op(op).Qual(v.ImportPath, v.Name) }
@@ -471,7 +631,6 @@ func (g *generator) decodeCaseCode(v *structure.Structure, asArray bool) (states
 	}
 
 	states = append(states, Case(caseStatement("*")).Block(
-		Id(ptn.IdDecoder).Op(":=").Qual(ptn.PkDec, "NewDecoder").Call(Id("data")),
 		List(Id("offset"), Err()).Op(":=").Id(decodeFuncName).Call(Id("v"), Id(ptn.IdDecoder), Id("0")),
 		If(Err().Op("==").Nil().Op("&&").Id("offset").Op("!=").Id(ptn.IdDecoder).Dot("Len").Call()).Block(
 			Return(True(), Qual("fmt", "Errorf").Call(Lit("read length is different [%d] [%d] "), Id("offset"), Id(ptn.IdDecoder).Dot("Len").Call())),
@@ -480,7 +639,6 @@ func (g *generator) decodeCaseCode(v *structure.Structure, asArray bool) (states
 
 	if g.pointer > 0 {
 		states = append(states, Case(caseStatement("**")).Block(
-			Id(ptn.IdDecoder).Op(":=").Qual(ptn.PkDec, "NewDecoder").Call(Id("data")),
 			List(Id("offset"), Err()).Op(":=").Id(decodeFuncName).Call(Id("*v"), Id(ptn.IdDecoder), Id("0")),
 			If(Err().Op("==").Nil().Op("&&").Id("offset").Op("!=").Id(ptn.IdDecoder).Dot("Len").Call()).Block(
 				Return(True(), Qual("fmt", "Errorf").Call(Lit("read length is different [%d] [%d] "), Id("offset"), Id(ptn.IdDecoder).Dot("Len").Call())),
@@ -491,7 +649,7 @@ func (g *generator) decodeCaseCode(v *structure.Structure, asArray bool) (states
 	for i := 0; i < g.pointer-1; i++ {
 		ptr := strings.Repeat("*", i+3)
 		pointers = append(pointers, Case(caseStatement(ptr)).Block(
-			Return(Id(ptn.PrivateFuncName(pointerFuncName)).Call(Id("data"), Id("*v"))),
+			Return(Id(ptn.PrivateFuncName(pointerFuncName)).Call(Id(ptn.IdDecoder), Id("*v"))),
 		))
 	}
 	return
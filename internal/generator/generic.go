@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"go/ast"
+	"go/types"
+
+	"github.com/shamaton/msgpackgen/internal/generator/structure"
+	"golang.org/x/tools/go/packages"
+)
+
+// createGenericInstance returns the structure of an instantiated generic struct, e.g. Page[int].
+// identical instances share the same structure.
+func (g *generator) createGenericInstance(typ *types.Named) (*structure.Structure, error) {
+	key := typeKey(typ)
+	if st, ok := g.typeCodeStructs[key]; ok {
+		return st, nil
+	}
+
+	obj := typ.Obj()
+	if !obj.Exported() && obj.Pkg().Path() != g.outputImportPath {
+		return nil, fmt.Errorf("generic struct %s is not exported", typ.String())
+	}
+	if g.directives[obj].ignore {
+		return nil, fmt.Errorf("generic struct %s is ignored by directive", typ.String())
+	}
+	typeCode, err := g.typeCode(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &structure.Structure{
+		ImportPath: obj.Pkg().Path(),
+		Package:    obj.Pkg().Name(),
+		Name:       fmt.Sprintf("%s_%x", obj.Name(), sha256.Sum256([]byte(key)))[:len(obj.Name())+1+16],
+		NoUseQual:  obj.Pkg().Path() == g.outputImportPath,
+		TypeCode:   typeCode,
+		TypeString: types.TypeString(typ, g.qualifier),
+	}
+	g.typeCodeStructs[key] = st
+	g.appendStructure(st, typ.Underlying().(*types.Struct), obj)
+	return st, nil
+}
+
+// createListedInstances creates the instances listed by blank variables, e.g. var _ Page[int].
+func (g *generator) createListedInstances(pkg *packages.Package, spec *ast.ValueSpec) error {
+	for _, name := range spec.Names {
+		if name.Name != "_" {
+			return nil
+		}
+	}
+
+	var typ types.Type
+	if spec.Type != nil {
+		typ = pkg.TypesInfo.TypeOf(spec.Type)
+	} else if len(spec.Values) == 1 {
+		typ = pkg.TypesInfo.TypeOf(spec.Values[0])
+	}
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+
+	named, ok := typ.(*types.Named)
+	if !ok || named.TypeArgs().Len() < 1 {
+		return nil
+	}
+	if _, ok := named.Underlying().(*types.Struct); !ok || g.directives[named.Obj()].ignore {
+		return nil
+	}
+	if _, err := g.createGenericInstance(named); err != nil {
+		return fmt.Errorf("can not instantiate %s: %v", named.String(), err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"fmt"
+	"go/types"
+	"path/filepath"
+
+	. "github.com/dave/jennifer/jen"
+	"github.com/shamaton/msgpackgen/internal/generator/ptn"
+	"github.com/shamaton/msgpackgen/internal/generator/structure"
+)
+
+// codecKinds are pairs of Marshal and Unmarshal methods, e.g. MarshalMsgpack and UnmarshalMsgpack.
+// Binary implements encoding.BinaryMarshaler and encoding.BinaryUnmarshaler.
+var codecKinds = []string{"Msgpack", "Binary"}
+
+// codecMethods returns kinds of methods to be generated on the type of obj.
+// a pair is not generated when the user declares either of it.
+func (g *generator) codecMethods(obj *types.TypeName) []string {
+	var kinds []string
+	for _, kind := range codecKinds {
+		if !g.declared(obj, "Marshal"+kind) && !g.declared(obj, "Unmarshal"+kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// declared reports whether the type of obj has the field or method of name, except methods in the file to be generated.
+func (g *generator) declared(obj *types.TypeName, name string) bool {
+	member, index, _ := types.LookupFieldOrMethod(types.NewPointer(obj.Type()), false, obj.Pkg(), name)
+	if member == nil || len(index) > 1 {
+		// methods promoted from embedded fields are overridden
+		return false
+	}
+	if g.outputFset == nil {
+		return true
+	}
+	return filepath.Clean(g.outputFset.Position(member.Pos()).Filename) != g.outputFile
+}
+
+func (g *generator) createMethodCode(f *File, st *structure.Structure) {
+	for _, kind := range g.structMethods[st] {
+		marshal, unmarshal := "Marshal"+kind, "Unmarshal"+kind
+
+		f.Comment(fmt.Sprintf("// %s encodes v with the generated code.\n", marshal)).
+			Func().Params(Id("v").Id(st.Name)).Id(marshal).Params().Params(Index().Byte(), Error()).Block(
+			Return(Id(ptn.PrivateFuncName("encode")).Call(Id("v"))),
+		)
+
+		f.Comment(fmt.Sprintf("// %s decodes data to v with the generated code.\n", unmarshal)).
+			Func().Params(Id("v").Op("*").Id(st.Name)).Id(unmarshal).Params(Id("data").Index().Byte()).Error().Block(
+			Return(Id(ptn.PrivateFuncName("decode")).Call(Id("data"), Id("v"))),
+		)
+	}
+}
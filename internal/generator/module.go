@@ -0,0 +1,198 @@
+package generator
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// moduleResolver works out import paths of directories in module mode.
+// replace directives of the main modules (and go.work) take precedence over
+// a go.mod in the same directory, because that is how the go command sees them.
+type moduleResolver struct {
+	replaces    []moduleReplace
+	modulePaths map[string]string
+}
+
+type moduleReplace struct {
+	path string
+	dir  string
+}
+
+func newModuleResolver(dir string) (*moduleResolver, error) {
+	r := &moduleResolver{modulePaths: map[string]string{}}
+
+	var mainModFiles []string
+	if work := findGoWork(dir); work != "" {
+		data, err := ioutil.ReadFile(work)
+		if err != nil {
+			return nil, err
+		}
+		wf, err := modfile.ParseWork(work, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		base := filepath.Dir(work)
+		r.addReplaces(base, wf.Replace)
+		for _, use := range wf.Use {
+			mainModFiles = append(mainModFiles, filepath.Join(localPath(base, use.Path), "go.mod"))
+		}
+	} else if mod := findUp(dir, "go.mod"); mod != "" {
+		mainModFiles = append(mainModFiles, mod)
+	}
+
+	for _, mod := range mainModFiles {
+		data, err := ioutil.ReadFile(mod)
+		if err != nil {
+			return nil, err
+		}
+		mf, err := modfile.Parse(mod, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		r.addReplaces(filepath.Dir(mod), mf.Replace)
+	}
+
+	// the deepest replacement directory wins
+	sort.SliceStable(r.replaces, func(i, j int) bool {
+		return len(r.replaces[i].dir) > len(r.replaces[j].dir)
+	})
+	return r, nil
+}
+
+func (r *moduleResolver) addReplaces(base string, replaces []*modfile.Replace) {
+	for _, rep := range replaces {
+		// only replacements by local directories change import paths
+		if !modfile.IsDirectoryPath(rep.New.Path) {
+			continue
+		}
+		found := false
+		for _, v := range r.replaces {
+			// go.work is read first and overrides go.mod
+			if v.path == rep.Old.Path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			r.replaces = append(r.replaces, moduleReplace{path: rep.Old.Path, dir: localPath(base, rep.New.Path)})
+		}
+	}
+}
+
+// importPath returns the import path of dir and false when dir belongs to no module.
+// The innermost module root wins, whether it comes from a replace directive or the nearest go.mod,
+// so modules nested in a replaced directory keep their own paths.
+func (r *moduleResolver) importPath(dir string) (string, bool, error) {
+	var replace *moduleReplace
+	for i, rep := range r.replaces {
+		if _, ok := relativePath(rep.dir, dir); ok {
+			replace = &r.replaces[i]
+			break
+		}
+	}
+
+	mod := findUp(dir, "go.mod")
+	if mod != "" && (replace == nil || len(filepath.Dir(mod)) > len(replace.dir)) {
+		modulePath, err := r.modulePath(mod)
+		if err != nil {
+			return "", false, err
+		}
+		rel, _ := relativePath(filepath.Dir(mod), dir)
+		return joinImportPath(modulePath, rel), true, nil
+	}
+	if replace == nil {
+		return "", false, nil
+	}
+	rel, _ := relativePath(replace.dir, dir)
+	return joinImportPath(replace.path, rel), true, nil
+}
+
+func (r *moduleResolver) modulePath(filename string) (string, error) {
+	if modulePath, ok := r.modulePaths[filename]; ok {
+		return modulePath, nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	modulePath := modfile.ModulePath(data)
+	if modulePath == "" {
+		return "", fmt.Errorf("module directive is not found in %s", filename)
+	}
+	r.modulePaths[filename] = modulePath
+	return modulePath, nil
+}
+
+func findGoWork(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+		return findUp(dir, "go.work")
+	default:
+		return gowork
+	}
+}
+
+func findUp(dir, name string) string {
+	for {
+		candidate := filepath.Join(dir, name)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func localPath(base, p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(base, filepath.FromSlash(p))
+}
+
+// relativePath returns the slash separated path of target from base if target is inside base.
+func relativePath(base, target string) (string, bool) {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return "", false
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return rel, true
+}
+
+func joinImportPath(modulePath, rel string) string {
+	if rel == "" || rel == "." {
+		return modulePath
+	}
+	return path.Join(modulePath, rel)
+}
+
+func getGoPathImportPath(dir string) (string, error) {
+	for _, goPath := range filepath.SplitList(build.Default.GOPATH) {
+		if goPath == "" {
+			continue
+		}
+		rel, ok := relativePath(filepath.Join(goPath, "src"), dir)
+		if !ok || rel == "." {
+			continue
+		}
+		return rel, nil
+	}
+	return "", fmt.Errorf("path %s is outside of any module and GOPATH", dir)
+}
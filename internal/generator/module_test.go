@@ -0,0 +1,135 @@
+package generator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestModule writes files into a new module of modulePath in a temporary directory.
+// go.work is turned off while the test runs.
+func newTestModule(t *testing.T, modulePath string, files map[string]string) string {
+	t.Helper()
+	t.Setenv("GOWORK", "off")
+	root := t.TempDir()
+	writeTestFile(t, root, "go.mod", "module "+modulePath+"\n")
+	for name, content := range files {
+		writeTestFile(t, root, name, content)
+	}
+	return root
+}
+
+// testConfig returns the config to generate the module at root.
+func testConfig(root string) Config {
+	return Config{
+		Input:    root,
+		Output:   root,
+		FileName: "resolver.msgpackgen.go",
+		Pointer:  1,
+	}
+}
+
+func writeTestFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, root, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestModuleImportPath(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "app/go.mod", `// main module
+module "example.com/app" // quoted
+
+go 1.22.3
+
+toolchain go1.22.5
+
+require example.com/lib v1.0.0
+
+replace (
+	example.com/lib v1.0.0 => ../lib
+	example.com/remote => example.com/fork v1.2.3
+)
+`)
+	writeTestFile(t, root, "lib/go.mod", "module example.com/renamed\n")
+	writeTestFile(t, root, "tool/go.mod", "module example.com/tool\n")
+	writeTestFile(t, root, "go.work", "go 1.22\n\nuse (\n\t./app\n\t./tool\n)\n\nreplace example.com/tool => ./tool\n")
+
+	t.Setenv("GOWORK", "")
+
+	r, err := newModuleResolver(filepath.Join(root, "app", "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir  string
+		want string
+		ok   bool
+	}{
+		{dir: "app", want: "example.com/app", ok: true},
+		{dir: "app/a/b", want: "example.com/app/a/b", ok: true},
+		// replaced module is seen by the path in replace directive
+		{dir: "lib/sub", want: "example.com/lib/sub", ok: true},
+		{dir: "tool/cmd", want: "example.com/tool/cmd", ok: true},
+		{dir: "", ok: false},
+	}
+	for _, tt := range tests {
+		got, ok, err := r.importPath(filepath.Join(root, filepath.FromSlash(tt.dir)))
+		if err != nil {
+			t.Fatal(tt.dir, err)
+		}
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("%s: got %s %v, want %s %v", tt.dir, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestModuleImportPathNested(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, root, "go.mod", "module example.com/root\n")
+	writeTestFile(t, root, "app/go.mod", "module example.com/app\n\nreplace example.com/root => ../\n")
+	writeTestFile(t, root, "sub/go.mod", "module example.com/sub\n")
+
+	t.Setenv("GOWORK", "off")
+
+	r, err := newModuleResolver(filepath.Join(root, "app"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir  string
+		want string
+	}{
+		{dir: "", want: "example.com/root"},
+		{dir: "pkg", want: "example.com/root/pkg"},
+		// nested modules are not captured by the replace of the ancestor directory
+		{dir: "app/a", want: "example.com/app/a"},
+		{dir: "sub/a/b", want: "example.com/sub/a/b"},
+	}
+	for _, tt := range tests {
+		got, ok, err := r.importPath(filepath.Join(root, filepath.FromSlash(tt.dir)))
+		if err != nil {
+			t.Fatal(tt.dir, err)
+		}
+		if !ok || got != tt.want {
+			t.Errorf("%s: got %s %v, want %s", tt.dir, got, ok, tt.want)
+		}
+	}
+}
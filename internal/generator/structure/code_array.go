@@ -10,19 +10,20 @@ import (
 type arrayCodeGen struct {
 }
 
-func (st *Structure) createArrayCode(node *Node, encodeFieldName, decodeFieldName string) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+func (st *Structure) createArrayCode(node *Node, encodeFieldName, decodeFieldName string, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
 
 	encodeChildName := encodeFieldName + "v"
 	if isRootField(encodeFieldName) {
 		encodeChildName = "vv"
 	}
 
+	decodeFieldName = decodeTargetName(decodeFieldName)
 	decodeChildName := decodeFieldName + "v"
 	if isRootField(decodeFieldName) {
 		decodeChildName = "vv"
 	}
 
-	ca, cm, ea, em, da, dm := st.createFieldCode(node.Elm(), encodeChildName, decodeChildName)
+	ca, cm, ea, em, da, dm := st.createFieldCode(node.Elm(), encodeChildName, decodeChildName, path.elm(Id(decodeChildName+"i")))
 	isChildByte := node.Elm().IsIdentical() && node.Elm().IdenticalName == "byte"
 
 	g := arrayCodeGen{}
@@ -32,8 +33,8 @@ func (st *Structure) createArrayCode(node *Node, encodeFieldName, decodeFieldNam
 	eArray = g.createEncCode(encodeFieldName, encodeChildName, isChildByte, ea)
 	eMap = g.createEncCode(encodeFieldName, encodeChildName, isChildByte, em)
 
-	dArray = g.createDecCode(node, st.Others, decodeFieldName, decodeChildName, da)
-	dMap = g.createDecCode(node, st.Others, decodeFieldName, decodeChildName, dm)
+	dArray = g.createDecCode(node, st.Others, decodeFieldName, decodeChildName, da, path)
+	dMap = g.createDecCode(node, st.Others, decodeFieldName, decodeChildName, dm, path)
 
 	return
 }
@@ -66,17 +67,22 @@ func (g arrayCodeGen) createEncCode(fieldName, childName string, isChildByte boo
 	return codes
 }
 
-func (g arrayCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, childName string, elmCodes []Code) []Code {
+func (g arrayCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, childName string, elmCodes []Code, path decodePath) []Code {
 
 	blockCodes := make([]Code, 0)
 	blockCodes = append(blockCodes, node.TypeJenChain(structures, Var().Id(childName)))
 	blockCodes = append(blockCodes, Var().Id(childName+"l").Int())
-	blockCodes = append(blockCodes, List(Id(childName+"l"), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot("SliceLength").Call(Id("offset")))
+	// bin is read as elements only by slices of byte
+	lengthFunc := "ArrayLength"
+	if node.Elm().IsIdentical() && node.Elm().IdenticalName == "byte" {
+		lengthFunc = "SliceLength"
+	}
+	blockCodes = append(blockCodes, List(Id(childName+"l"), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot(lengthFunc).Call(Id("offset")))
 	blockCodes = append(blockCodes, If(Err().Op("!=").Nil()).Block(
-		Return(Lit(0), Err()),
+		createDecodeErrReturnCode(path),
 	))
 	blockCodes = append(blockCodes, If(Id(childName+"l").Op(">").Id(fmt.Sprint(node.ArrayLen))).Block(
-		Return(Lit(0), Qual("fmt", "Errorf").Call(Lit("length size(%d) is over array size(%d)"), Id(childName+"l"), Id(fmt.Sprint(node.ArrayLen)))),
+		Return(Lit(0), path.wrapErrorCode(Qual("fmt", "Errorf").Call(Lit("length size(%d) is over array size(%d)"), Id(childName+"l"), Id(fmt.Sprint(node.ArrayLen))))),
 	))
 
 	elmCodes = append([]Code{node.Elm().TypeJenChain(structures, Var().Id(childName+"v"))}, elmCodes...)
@@ -0,0 +1,70 @@
+package structure
+
+import (
+	. "github.com/dave/jennifer/jen"
+	"github.com/shamaton/msgpackgen/internal/generator/ptn"
+)
+
+type extCodeGen struct {
+}
+
+func (st *Structure) createExtCode(node *Node, encodeFieldName, decodeFieldName string, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+	g := extCodeGen{}
+	cArray = g.createCalcCode(node, Id(encodeFieldName))
+	cMap = g.createCalcCode(node, Id(encodeFieldName))
+
+	eArray = g.createEncCode(node, Id(encodeFieldName))
+	eMap = g.createEncCode(node, Id(encodeFieldName))
+
+	dArray = g.createDecCode(node, st.Others, decodeFieldName, path)
+	dMap = g.createDecCode(node, st.Others, decodeFieldName, path)
+	return
+}
+
+func (g extCodeGen) createCalcCode(node *Node, field Code) []Code {
+	return []Code{
+		Block(
+			List(Id("s"), Err()).Op(":=").Qual(ptn.PkEnc, "CalcExt").Index(node.ExtType).Call(Id(ptn.IdEncoder), Lit(int(node.ExtCode)), field),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Lit(0), Err()),
+			),
+			Id("size").Op("+=").Id("s"),
+		),
+	}
+}
+
+func (g extCodeGen) createEncCode(node *Node, field Code) []Code {
+	return []Code{
+		List(Id("offset"), Err()).Op("=").Qual(ptn.PkEnc, "WriteExt").Index(node.ExtType).Call(Id(ptn.IdEncoder), Lit(int(node.ExtCode)), field, Id("offset")),
+		If(Err().Op("!=").Nil()).Block(
+			Return(Nil(), Lit(0), Err()),
+		),
+	}
+}
+
+func (g extCodeGen) createDecCode(node *Node, structures []*Structure, fieldName string, path decodePath) []Code {
+	varName := fieldName + "v"
+	if isRootField(fieldName) {
+		varName = "vv"
+	}
+
+	_, isParentTypeArrayOrMap := node.GetPointerInfo()
+
+	codes, receiverName := createDecodeDefineVarCode(node, structures, varName)
+
+	codes = append(codes,
+		List(Id(receiverName), Id("offset"), Err()).Op("=").Qual(ptn.PkDec, "AsExt").Index(node.ExtType).Call(Id(ptn.IdDecoder), Lit(int(node.ExtCode)), Id("offset")),
+		If(Err().Op("!=").Nil()).Block(
+			createDecodeErrReturnCode(path),
+		),
+	)
+
+	codes = append(codes, createDecodeSetValueCode(node, varName, fieldName)...)
+
+	// array or map
+	if isParentTypeArrayOrMap {
+		return codes
+	}
+
+	return []Code{Block(codes...)}
+}
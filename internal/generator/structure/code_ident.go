@@ -10,20 +10,26 @@ import (
 type identCodeGen struct {
 }
 
-func (st *Structure) createIdentCode(node *Node, encodeFieldName, decodeFieldName string) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+func (st *Structure) createIdentCode(node *Node, encodeFieldName, decodeFieldName string, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
 
 	funcSuffix := strings.Title(node.IdenticalName)
 
 	g := identCodeGen{}
 
-	cArray = g.createCalcCode("Calc"+funcSuffix, Id(encodeFieldName))
-	cMap = g.createCalcCode("Calc"+funcSuffix, Id(encodeFieldName))
+	// named types are converted to the underlying type
+	encodeField := Id(encodeFieldName)
+	if node.IsNamed() {
+		encodeField = Id(node.IdenticalName).Call(Id(encodeFieldName))
+	}
+
+	cArray = g.createCalcCode("Calc"+funcSuffix, encodeField)
+	cMap = g.createCalcCode("Calc"+funcSuffix, encodeField)
 
-	eArray = g.createEncCode("Write"+funcSuffix, Id(encodeFieldName), Id("offset"))
-	eMap = g.createEncCode("Write"+funcSuffix, Id(encodeFieldName), Id("offset"))
+	eArray = g.createEncCode("Write"+funcSuffix, encodeField, Id("offset"))
+	eMap = g.createEncCode("Write"+funcSuffix, encodeField, Id("offset"))
 
-	dArray = g.createDecCode(node, st.Others, decodeFieldName, "As"+funcSuffix)
-	dMap = g.createDecCode(node, st.Others, decodeFieldName, "As"+funcSuffix)
+	dArray = g.createDecCode(node, st.Others, decodeFieldName, "As"+funcSuffix, path)
+	dMap = g.createDecCode(node, st.Others, decodeFieldName, "As"+funcSuffix, path)
 
 	return
 }
@@ -40,7 +46,7 @@ func (g identCodeGen) createEncCode(funcName string, params ...Code) []Code {
 	}
 }
 
-func (g identCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, funcName string) []Code {
+func (g identCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, funcName string, path decodePath) []Code {
 
 	varName := fieldName + "v"
 	if isRootField(fieldName) {
@@ -51,12 +57,24 @@ func (g identCodeGen) createDecCode(node *Node, structures []*Structure, fieldNa
 
 	codes, receiverName := createDecodeDefineVarCode(node, structures, varName)
 
-	codes = append(codes,
-		List(Id(receiverName), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot(funcName).Call(Id("offset")),
-		If(Err().Op("!=").Nil()).Block(
-			Return(Lit(0), Err()),
-		),
-	)
+	if node.IsNamed() {
+		underlyingName := receiverName + "u"
+		codes = append(codes, Block(
+			Var().Id(underlyingName).Id(node.IdenticalName),
+			List(Id(underlyingName), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot(funcName).Call(Id("offset")),
+			If(Err().Op("!=").Nil()).Block(
+				createDecodeErrReturnCode(path),
+			),
+			Id(receiverName).Op("=").Add(node.TypeJenChain(structures)).Call(Id(underlyingName)),
+		))
+	} else {
+		codes = append(codes,
+			List(Id(receiverName), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot(funcName).Call(Id("offset")),
+			If(Err().Op("!=").Nil()).Block(
+				createDecodeErrReturnCode(path),
+			),
+		)
+	}
 
 	codes = append(codes, createDecodeSetValueCode(node, varName, fieldName)...)
 
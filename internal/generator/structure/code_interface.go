@@ -0,0 +1,64 @@
+package structure
+
+import (
+	. "github.com/dave/jennifer/jen"
+	"github.com/shamaton/msgpackgen/internal/generator/ptn"
+)
+
+type interfaceCodeGen struct {
+}
+
+func (st *Structure) createInterfaceCode(node *Node, encodeFieldName, decodeFieldName string, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+	g := interfaceCodeGen{}
+	cArray = g.createCalcCode(Id(encodeFieldName), Lit(true))
+	cMap = g.createCalcCode(Id(encodeFieldName), Lit(false))
+
+	eArray = g.createEncCode(Id(encodeFieldName), Id("offset"), Lit(true))
+	eMap = g.createEncCode(Id(encodeFieldName), Id("offset"), Lit(false))
+
+	dArray = g.createDecCode(node, st.Others, decodeFieldName, path)
+	dMap = g.createDecCode(node, st.Others, decodeFieldName, path)
+	return
+}
+
+func (g interfaceCodeGen) createCalcCode(params ...Code) []Code {
+	return []Code{
+		Block(createAddSizeErrCheckCode("CalcInterface", params...)...),
+	}
+}
+
+func (g interfaceCodeGen) createEncCode(params ...Code) []Code {
+	return []Code{
+		List(Id("offset"), Err()).Op("=").Id(ptn.IdEncoder).Dot("WriteInterface").Call(params...),
+		If(Err().Op("!=").Nil()).Block(
+			Return(Nil(), Lit(0), Err()),
+		),
+	}
+}
+
+func (g interfaceCodeGen) createDecCode(node *Node, structures []*Structure, fieldName string, path decodePath) []Code {
+	varName := fieldName + "v"
+	if isRootField(fieldName) {
+		varName = "vv"
+	}
+
+	_, isParentTypeArrayOrMap := node.GetPointerInfo()
+
+	codes, receiverName := createDecodeDefineVarCode(node, structures, varName)
+
+	codes = append(codes,
+		List(Id(receiverName), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot("AsInterface").Call(Id("offset")),
+		If(Err().Op("!=").Nil()).Block(
+			createDecodeErrReturnCode(path),
+		),
+	)
+
+	codes = append(codes, createDecodeSetValueCode(node, varName, fieldName)...)
+
+	// array or map
+	if isParentTypeArrayOrMap {
+		return codes
+	}
+
+	return []Code{Block(codes...)}
+}
@@ -8,7 +8,7 @@ import (
 type mapCodeGen struct {
 }
 
-func (st *Structure) createMapCode(node *Node, encodeFieldName, decodeFieldName string) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+func (st *Structure) createMapCode(node *Node, encodeFieldName, decodeFieldName string, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
 
 	key, value := node.KeyValue()
 
@@ -18,14 +18,15 @@ func (st *Structure) createMapCode(node *Node, encodeFieldName, decodeFieldName
 		encodeChildValue = "vv"
 	}
 
+	decodeFieldName = decodeTargetName(decodeFieldName)
 	decodeChildKey, decodeChildValue := decodeFieldName+"k", decodeFieldName+"v"
 	if isRootField(decodeFieldName) {
 		decodeChildKey = "kk"
 		decodeChildValue = "vv"
 	}
 
-	caKey, cmKey, eaKey, emKey, daKey, dmKey := st.createFieldCode(key, encodeChildKey, decodeChildKey)
-	caValue, cmValue, eaValue, emValue, daValue, dmValue := st.createFieldCode(value, encodeChildValue, decodeChildValue)
+	caKey, cmKey, eaKey, emKey, daKey, dmKey := st.createFieldCode(key, encodeChildKey, decodeChildKey, path)
+	caValue, cmValue, eaValue, emValue, daValue, dmValue := st.createFieldCode(value, encodeChildValue, decodeChildValue, path.elm(Id(decodeChildKey+"v")))
 
 	g := mapCodeGen{}
 	cArray = g.createCalcCode(encodeFieldName, encodeChildKey, encodeChildValue, caKey, caValue)
@@ -34,8 +35,8 @@ func (st *Structure) createMapCode(node *Node, encodeFieldName, decodeFieldName
 	eArray = g.createEncCode(encodeFieldName, encodeChildKey, encodeChildValue, eaKey, eaValue)
 	eMap = g.createEncCode(encodeFieldName, encodeChildKey, encodeChildValue, emKey, emValue)
 
-	dArray = g.createDecCode(node, st.Others, decodeFieldName, decodeChildKey, decodeChildValue, daKey, daValue)
-	dMap = g.createDecCode(node, st.Others, decodeFieldName, decodeChildKey, decodeChildValue, dmKey, dmValue)
+	dArray = g.createDecCode(node, st.Others, decodeFieldName, decodeChildKey, decodeChildValue, daKey, daValue, path)
+	dMap = g.createDecCode(node, st.Others, decodeFieldName, decodeChildKey, decodeChildValue, dmKey, dmValue, path)
 
 	return
 }
@@ -79,16 +80,19 @@ func (g mapCodeGen) createEncCode(
 func (g mapCodeGen) createDecCode(
 	ast *Node, structures []*Structure,
 	fieldName, childKeyName, childValueName string,
-	elmKeyCodes, elmValueCodes []Code) []Code {
+	elmKeyCodes, elmValueCodes []Code, path decodePath) []Code {
 
 	decCodes := make([]Code, 0)
 	decCodes = append(decCodes, ast.TypeJenChain(structures, Var().Id(childValueName)))
 	decCodes = append(decCodes, Var().Id(childValueName+"l").Int())
 	decCodes = append(decCodes, List(Id(childValueName+"l"), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot("MapLength").Call(Id("offset")))
 	decCodes = append(decCodes, If(Err().Op("!=").Nil()).Block(
-		Return(Lit(0), Err()),
+		createDecodeErrReturnCode(path),
+	))
+	decCodes = append(decCodes, List(Id(childValueName), Err()).Op("=").Qual(ptn.PkDec, "MakeMap").Index(List(ast.Key.TypeJenChain(structures), ast.Value.TypeJenChain(structures))).Call(Id(ptn.IdDecoder), Id("offset"), Id(childValueName+"l")))
+	decCodes = append(decCodes, If(Err().Op("!=").Nil()).Block(
+		createDecodeErrReturnCode(path),
 	))
-	decCodes = append(decCodes, Id(childValueName).Op("=").Make(ast.TypeJenChain(structures), Id(childValueName+"l")))
 
 	da := []Code{ast.Key.TypeJenChain(structures, Var().Id(childKeyName+"v"))}
 	da = append(da, elmKeyCodes...)
@@ -10,7 +10,7 @@ import (
 type namedCodeGen struct {
 }
 
-func (st *Structure) createNamedCode(encodeFieldName, decodeFieldName string, ast *Node) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+func (st *Structure) createNamedCode(encodeFieldName, decodeFieldName string, ast *Node, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
 
 	sizeName := "size_" + encodeFieldName
 	if isRootField(encodeFieldName) {
@@ -24,8 +24,8 @@ func (st *Structure) createNamedCode(encodeFieldName, decodeFieldName string, as
 	eArray = g.createEncCode(ast, encodeFieldName, "encodeArray")
 	eMap = g.createEncCode(ast, encodeFieldName, "encodeMap")
 
-	dArray = g.createDecCode(ast, st.Others, decodeFieldName, "decodeArray")
-	dMap = g.createDecCode(ast, st.Others, decodeFieldName, "decodeMap")
+	dArray = g.createDecCode(ast, st.Others, decodeFieldName, "decodeArray", path)
+	dMap = g.createDecCode(ast, st.Others, decodeFieldName, "decodeMap", path)
 
 	return
 }
@@ -55,7 +55,7 @@ func (g namedCodeGen) createEncCode(node *Node, fieldName, funcName string) []Co
 	}
 }
 
-func (g namedCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, funcName string) []Code {
+func (g namedCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, funcName string, path decodePath) []Code {
 
 	varName := fieldName + "v"
 	if isRootField(fieldName) {
@@ -71,7 +71,7 @@ func (g namedCodeGen) createDecCode(node *Node, structures []*Structure, fieldNa
 			createFuncName(funcName, node.StructName, node.ImportPath)).Call(Op("&").Id(receiverName),
 			Id(ptn.IdDecoder), Id("offset")),
 		If(Err().Op("!=").Nil()).Block(
-			Return(Lit(0), Err()),
+			createDecodeErrReturnCode(path),
 		),
 	)
 
@@ -8,14 +8,14 @@ import (
 type pointerCodeGen struct {
 }
 
-func (st *Structure) createPointerCode(node *Node, encodeFieldName, decodeFieldName string) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+func (st *Structure) createPointerCode(node *Node, encodeFieldName, decodeFieldName string, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
 
 	encodeChildName := encodeFieldName + "p"
 	if isRootField(encodeFieldName) {
 		encodeChildName = "vp"
 	}
 
-	ca, cm, ea, em, da, dm := st.createFieldCode(node.Elm(), encodeChildName, decodeFieldName)
+	ca, cm, ea, em, da, dm := st.createFieldCode(node.Elm(), encodeChildName, decodeFieldName, path)
 
 	g := pointerCodeGen{}
 	cArray = g.createPointerCalcCode(encodeFieldName, encodeChildName, ca)
@@ -8,17 +8,20 @@ import (
 type sliceCodeGen struct {
 }
 
-func (st *Structure) createSliceCode(node *Node, encodeFieldName, decodeFieldName string) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+func (st *Structure) createSliceCode(node *Node, encodeFieldName, decodeFieldName string, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
 
-	encodeChildName, decodeChildName := encodeFieldName+"v", decodeFieldName+""
+	encodeChildName := encodeFieldName + "v"
 	if isRootField(encodeFieldName) {
 		encodeChildName = "vv"
 	}
+
+	decodeFieldName = decodeTargetName(decodeFieldName)
+	decodeChildName := decodeFieldName + "v"
 	if isRootField(decodeFieldName) {
 		decodeChildName = "vv"
 	}
 
-	ca, cm, ea, em, da, dm := st.createFieldCode(node.Elm(), encodeChildName, decodeChildName)
+	ca, cm, ea, em, da, dm := st.createFieldCode(node.Elm(), encodeChildName, decodeChildName, path.elm(Id(decodeChildName+"i")))
 	isChildByte := node.Elm().IsIdentical() && node.Elm().IdenticalName == "byte"
 
 	g := sliceCodeGen{}
@@ -29,8 +32,8 @@ func (st *Structure) createSliceCode(node *Node, encodeFieldName, decodeFieldNam
 	eArray = g.createEncCode(encodeFieldName, encodeChildName, isChildByte, ea)
 	eMap = g.createEncCode(encodeFieldName, encodeChildName, isChildByte, em)
 
-	dArray = g.createDecCode(node, st.Others, decodeFieldName, decodeChildName, da)
-	dMap = g.createDecCode(node, st.Others, decodeFieldName, decodeChildName, dm)
+	dArray = g.createDecCode(node, st.Others, decodeFieldName, decodeChildName, da, path)
+	dMap = g.createDecCode(node, st.Others, decodeFieldName, decodeChildName, dm, path)
 	return
 }
 
@@ -67,7 +70,7 @@ func (g sliceCodeGen) createEncCode(fieldName, childName string, isChildTypeByte
 	return codes
 }
 
-func (g sliceCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, childName string, elmCodes []Code) []Code {
+func (g sliceCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, childName string, elmCodes []Code, path decodePath) []Code {
 
 	childLengthName := childName + "l"
 	childIndexName := childName + "i"
@@ -76,11 +79,19 @@ func (g sliceCodeGen) createDecCode(node *Node, structures []*Structure, fieldNa
 	blockCodes := make([]Code, 0)
 	blockCodes = append(blockCodes, node.TypeJenChain(structures, Var().Id(childName)))
 	blockCodes = append(blockCodes, Var().Id(childLengthName).Int())
-	blockCodes = append(blockCodes, List(Id(childLengthName), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot("SliceLength").Call(Id("offset")))
+	// bin is read as elements only by slices of byte
+	lengthFunc := "ArrayLength"
+	if node.Elm().IsIdentical() && node.Elm().IdenticalName == "byte" {
+		lengthFunc = "SliceLength"
+	}
+	blockCodes = append(blockCodes, List(Id(childLengthName), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot(lengthFunc).Call(Id("offset")))
+	blockCodes = append(blockCodes, If(Err().Op("!=").Nil()).Block(
+		createDecodeErrReturnCode(path),
+	))
+	blockCodes = append(blockCodes, List(Id(childName), Err()).Op("=").Qual(ptn.PkDec, "MakeSlice").Index(node.Elm().TypeJenChain(structures)).Call(Id(ptn.IdDecoder), Id("offset"), Id(childLengthName)))
 	blockCodes = append(blockCodes, If(Err().Op("!=").Nil()).Block(
-		Return(Lit(0), Err()),
+		createDecodeErrReturnCode(path),
 	))
-	blockCodes = append(blockCodes, Id(childName).Op("=").Make(node.TypeJenChain(structures), Id(childLengthName)))
 
 	elmCodes = append([]Code{node.Elm().TypeJenChain(structures, Var().Id(childChildName))}, elmCodes...)
 	elmCodes = append(elmCodes, Id(childName).Index(Id(childIndexName)).Op("=").Id(childChildName))
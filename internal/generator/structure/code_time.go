@@ -8,7 +8,7 @@ import (
 type timeCodeGen struct {
 }
 
-func (st *Structure) createTimeCode(encodeFieldName, decodeFieldName string, node *Node) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+func (st *Structure) createTimeCode(encodeFieldName, decodeFieldName string, node *Node, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
 	g := timeCodeGen{}
 	cArray = g.createCalcCode("CalcTime", Id(encodeFieldName))
 	cMap = g.createCalcCode("CalcTime", Id(encodeFieldName))
@@ -16,8 +16,8 @@ func (st *Structure) createTimeCode(encodeFieldName, decodeFieldName string, nod
 	eArray = g.createEncCode("WriteTime", Id(encodeFieldName), Id("offset"))
 	eMap = g.createEncCode("WriteTime", Id(encodeFieldName), Id("offset"))
 
-	dArray = g.createDecCode(node, st.Others, decodeFieldName, "AsDateTime")
-	dMap = g.createDecCode(node, st.Others, decodeFieldName, "AsDateTime")
+	dArray = g.createDecCode(node, st.Others, decodeFieldName, "AsDateTime", path)
+	dMap = g.createDecCode(node, st.Others, decodeFieldName, "AsDateTime", path)
 	return
 }
 
@@ -34,7 +34,7 @@ func (g timeCodeGen) createEncCode(funcName string, params ...Code) []Code {
 	}
 }
 
-func (g timeCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, funcName string) []Code {
+func (g timeCodeGen) createDecCode(node *Node, structures []*Structure, fieldName, funcName string, path decodePath) []Code {
 	varName := fieldName + "v"
 	if isRootField(fieldName) {
 		varName = "vv"
@@ -47,7 +47,7 @@ func (g timeCodeGen) createDecCode(node *Node, structures []*Structure, fieldNam
 	codes = append(codes,
 		List(Id(receiverName), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot(funcName).Call(Id("offset")),
 		If(Err().Op("!=").Nil()).Block(
-			Return(Lit(0), Err()),
+			createDecodeErrReturnCode(path),
 		),
 	)
 
@@ -2,7 +2,6 @@ package structure
 
 import (
 	"fmt"
-	"go/ast"
 
 	. "github.com/dave/jennifer/jen"
 )
@@ -14,6 +13,8 @@ const (
 	fieldTypeStruct
 	fieldTypeMap
 	fieldTypePointer
+	fieldTypeInterface
+	fieldTypeExt
 )
 
 type Node struct {
@@ -34,9 +35,24 @@ type Node struct {
 	Key   *Node
 	Value *Node
 
+	// for named types except struct
+	Named *NamedType
+
+	// for ext types
+	ExtCode int8
+	ExtType Code
+
 	Parent *Node
 }
 
+type NamedType struct {
+	ImportPath string
+	Name       string
+	NoUseQual  bool
+	// for instantiated generic types
+	TypeCode Code
+}
+
 func (n Node) Elm() *Node               { return n.Key }
 func (n Node) KeyValue() (*Node, *Node) { return n.Key, n.Value }
 
@@ -46,6 +62,10 @@ func (n Node) IsArray() bool     { return n.fieldType == fieldTypeArray }
 func (n Node) IsStruct() bool    { return n.fieldType == fieldTypeStruct }
 func (n Node) IsMap() bool       { return n.fieldType == fieldTypeMap }
 func (n Node) IsPointer() bool   { return n.fieldType == fieldTypePointer }
+func (n Node) IsInterface() bool { return n.fieldType == fieldTypeInterface }
+func (n Node) IsExt() bool       { return n.fieldType == fieldTypeExt }
+
+func (n Node) IsNamed() bool { return n.Named != nil }
 
 func (n Node) HasParent() bool       { return n.Parent != nil }
 func (n Node) IsParentPointer() bool { return n.HasParent() && n.Parent.IsPointer() }
@@ -53,6 +73,10 @@ func (n Node) IsParentPointer() bool { return n.HasParent() && n.Parent.IsPointe
 func (n *Node) SetKeyNode(key *Node)     { n.Key = key }
 func (n *Node) SetValueNode(value *Node) { n.Value = value }
 
+func (n *Node) SetNamedType(importPath, name string, noUseQual bool) {
+	n.Named = &NamedType{ImportPath: importPath, Name: name, NoUseQual: noUseQual}
+}
+
 func (n *Node) GetPointerInfo() (ptrCount int, isParentTypeArrayOrMap bool) {
 	node := n
 	for node.HasParent() {
@@ -72,7 +96,7 @@ func (n *Node) GetPointerInfo() (ptrCount int, isParentTypeArrayOrMap bool) {
 func (n Node) CanGenerate(structures []*Structure) (bool, []string) {
 	messages := make([]string, 0)
 	switch {
-	case n.IsIdentical():
+	case n.IsIdentical(), n.IsInterface(), n.IsExt():
 		return true, messages
 
 	case n.IsStruct():
@@ -114,10 +138,26 @@ func (n Node) TypeJenChain(structures []*Structure, statements ...*Statement) *S
 		str = Id("")
 	}
 
+	if n.IsNamed() {
+		if n.Named.TypeCode != nil {
+			return str.Add(n.Named.TypeCode)
+		}
+		if n.Named.NoUseQual {
+			return str.Id(n.Named.Name)
+		}
+		return str.Qual(n.Named.ImportPath, n.Named.Name)
+	}
+
 	switch {
 	case n.IsIdentical():
 		str = str.Id(n.IdenticalName)
 
+	case n.IsInterface():
+		str = str.Interface()
+
+	case n.IsExt():
+		str = str.Add(n.ExtType)
+
 	case n.IsStruct():
 		if n.ImportPath == "time" && n.StructName == "Time" {
 			str = str.Qual(n.ImportPath, n.StructName)
@@ -134,7 +174,9 @@ func (n Node) TypeJenChain(structures []*Structure, statements ...*Statement) *S
 				panic(fmt.Sprintf("not found struct %s.%s", n.ImportPath, n.StructName))
 			}
 
-			if asRef.NoUseQual {
+			if asRef.HasTypeCode() {
+				str = str.Add(asRef.TypeCode)
+			} else if asRef.NoUseQual {
 				str = str.Id(n.StructName)
 			} else {
 				str = str.Qual(n.ImportPath, n.StructName)
@@ -163,10 +205,10 @@ func (n Node) TypeJenChain(structures []*Structure, statements ...*Statement) *S
 	return str
 }
 
-func CreateIdentNode(ident *ast.Ident, parent *Node) *Node {
+func CreateIdentNode(name string, parent *Node) *Node {
 	return &Node{
 		fieldType:     fieldTypeIdent,
-		IdenticalName: ident.Name,
+		IdenticalName: name,
 		Parent:        parent,
 	}
 }
@@ -181,6 +223,22 @@ func CreateStructNode(importPath, packageName, structName string, parent *Node)
 	}
 }
 
+func CreateInterfaceNode(parent *Node) *Node {
+	return &Node{
+		fieldType: fieldTypeInterface,
+		Parent:    parent,
+	}
+}
+
+func CreateExtNode(code int8, typeCode Code, parent *Node) *Node {
+	return &Node{
+		fieldType: fieldTypeExt,
+		ExtCode:   code,
+		ExtType:   typeCode,
+		Parent:    parent,
+	}
+}
+
 func CreateSliceNode(parent *Node) *Node {
 	return &Node{
 		fieldType: fieldTypeSlice,
@@ -13,6 +13,36 @@ func isRootField(name string) bool {
 	return strings.Contains(name, ".")
 }
 
+// decodeTargetName returns the name which the decoded value is set to.
+// children of slice, array and map are set to the variable declared by the parent.
+func decodeTargetName(fieldName string) string {
+	if isRootField(fieldName) {
+		return fieldName
+	}
+	return fieldName + "v"
+}
+
+// decodePath is the field being decoded and the indexes and keys of its elements, which are added to decode errors.
+type decodePath struct {
+	typ, field string
+	keys       []Code
+}
+
+// elm returns the path of the element at key.
+func (p decodePath) elm(key Code) decodePath {
+	keys := append(append([]Code{}, p.keys...), key)
+	return decodePath{typ: p.typ, field: p.field, keys: keys}
+}
+
+func (p decodePath) wrapErrorCode(err Code) Code {
+	params := append([]Code{err, Lit(p.typ), Lit(p.field)}, p.keys...)
+	return Id(ptn.IdDecoder).Dot("WrapError").Call(params...)
+}
+
+func createDecodeErrReturnCode(path decodePath) Code {
+	return Return(Lit(0), path.wrapErrorCode(Err()))
+}
+
 func createFuncName(prefix, name, importPath string) string {
 	suffix := fmt.Sprintf("%x", sha256.Sum256([]byte(importPath)))
 	return ptn.PrivateFuncName(fmt.Sprintf("%s%s_%s", prefix, name, suffix))
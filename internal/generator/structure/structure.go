@@ -2,7 +2,6 @@ package structure
 
 import (
 	"fmt"
-	"go/ast"
 	"math"
 
 	. "github.com/dave/jennifer/jen"
@@ -16,17 +15,71 @@ type Structure struct {
 	Fields     []Field
 	NoUseQual  bool
 
+	// type expression used instead of Name, for anonymous structs and instantiated generic structs
+	TypeCode   Code
+	TypeString string
+
+	// layout forced by the directive
+	Layout Layout
+	// arrays shorter or longer than the fields are accepted on decode
+	Evolvable bool
+
 	Others []*Structure
-	File   *ast.File
 
 	CanGen  bool
 	Reasons []string
 }
 
+// Layout forces the struct to be encoded as array or map regardless of StructAsArray.
+type Layout int
+
+const (
+	LayoutDefault Layout = iota
+	LayoutArray
+	LayoutMap
+)
+
 type Field struct {
 	Name string
 	Tag  string
-	Node *Node
+	// slot in array layout
+	Index int
+	// left out when empty in map layout
+	OmitEmpty bool
+	Node      *Node
+}
+
+func (st *Structure) HasTypeCode() bool {
+	return st.TypeCode != nil
+}
+
+// arraySlots returns indexes of fields by slot in array layout. gaps are -1.
+func (st *Structure) arraySlots() []int {
+	n := 0
+	for _, field := range st.Fields {
+		if field.Index+1 > n {
+			n = field.Index + 1
+		}
+	}
+	slots := make([]int, n)
+	for i := range slots {
+		slots[i] = -1
+	}
+	for i, field := range st.Fields {
+		slots[field.Index] = i
+	}
+	return slots
+}
+
+// asArrayLayout returns whether the struct is written as array in the mode.
+func (st *Structure) asArrayLayout(asArray bool) bool {
+	switch st.Layout {
+	case LayoutArray:
+		return true
+	case LayoutMap:
+		return false
+	}
+	return asArray
 }
 
 func (st *Structure) CalcArraySizeFuncName() string {
@@ -60,87 +113,170 @@ func (st *Structure) createFuncName(prefix string) string {
 func (st *Structure) CreateCode(f *File) {
 	v := "v"
 
-	calcStruct, encStructArray, encStructMap := st.createStructCode(len(st.Fields))
-
-	calcArraySizeCodes := make([]Code, 0)
-	calcArraySizeCodes = append(calcArraySizeCodes, Id("size").Op(":=").Lit(0))
-	calcArraySizeCodes = append(calcArraySizeCodes, calcStruct)
-
-	calcMapSizeCodes := make([]Code, 0)
-	calcMapSizeCodes = append(calcMapSizeCodes, Id("size").Op(":=").Lit(0))
-	calcMapSizeCodes = append(calcMapSizeCodes, calcStruct)
-
-	encArrayCodes := make([]Code, 0)
-	encArrayCodes = append(encArrayCodes, Var().Err().Error())
-	encArrayCodes = append(encArrayCodes, encStructArray)
-
-	encMapCodes := make([]Code, 0)
-	encMapCodes = append(encMapCodes, Var().Err().Error())
-	encMapCodes = append(encMapCodes, encStructMap)
-
-	decArrayCodes := make([]Code, 0)
-	decArrayCodes = append(decArrayCodes, List(Id("offset"), Err()).Op(":=").Id(ptn.IdDecoder).Dot("CheckStructHeader").Call(Lit(len(st.Fields)), Id("offset")))
-	decArrayCodes = append(decArrayCodes, If(Err().Op("!=").Nil()).Block(
-		Return(Lit(0), Err()),
-	))
-
-	decMapCodeSwitchCases := make([]Code, 0)
+	type fieldCode struct {
+		cArray, cMap, eArray, eMap, dArray, dMap []Code
+	}
+	// decode errors are reported with the name of the type
+	typeName := st.Name
+	if st.HasTypeCode() {
+		typeName = st.TypeString
+	}
 
+	fieldCodes := make([]fieldCode, 0, len(st.Fields))
 	for _, field := range st.Fields {
 		fieldName := "v." + field.Name
 
-		calcKeyStringCode, writeKeyStringCode := st.createKeyStringCode(field.Tag)
-		calcMapSizeCodes = append(calcMapSizeCodes, calcKeyStringCode)
-		encMapCodes = append(encMapCodes, writeKeyStringCode)
+		var fc fieldCode
+		fc.cArray, fc.cMap, fc.eArray, fc.eMap, fc.dArray, fc.dMap = st.createFieldCode(field.Node, fieldName, fieldName, decodePath{typ: typeName, field: field.Name})
+		fieldCodes = append(fieldCodes, fc)
+	}
 
-		cArray, cMap, eArray, eMap, dArray, dMap := st.createFieldCode(field.Node, fieldName, fieldName)
-		calcArraySizeCodes = append(calcArraySizeCodes, cArray...)
+	// errors in the header and skipped values have no field
+	structPath := decodePath{typ: typeName}
 
-		calcMapSizeCodes = append(calcMapSizeCodes, cMap...)
+	// the codes of fields depend on the mode, and the header and keys depend on the layout.
+	createModeCode := func(asArray bool) (calcCodes, encCodes, decCodes []Code) {
+		asArrayLayout := st.asArrayLayout(asArray)
 
-		encArrayCodes = append(encArrayCodes, eArray...)
-		encMapCodes = append(encMapCodes, eMap...)
+		// fields by slot in array layout, by declaration in map layout
+		order := make([]int, len(st.Fields))
+		for i := range order {
+			order[i] = i
+		}
+		if asArrayLayout {
+			order = st.arraySlots()
+		}
 
-		decArrayCodes = append(decArrayCodes, dArray...)
+		// in map layout, empty fields with omitempty are left out and the number of keys is counted at runtime
+		notEmpties := make([]Code, len(st.Fields))
+		countCodes := []Code{Id("n").Op(":=").Lit(len(st.Fields))}
+		for i, field := range st.Fields {
+			if asArrayLayout || !field.OmitEmpty {
+				continue
+			}
+			var empty Code
+			if empty, notEmpties[i] = emptyCode(field.Node, "v."+field.Name); empty != nil {
+				countCodes = append(countCodes, If(empty).Block(Id("n").Op("--")))
+			}
+		}
+		dynamic := len(countCodes) > 1
+
+		calcStruct, encStructArray, encStructMap := st.createStructCode(len(order))
+		calcCodes = append(calcCodes, Id("size").Op(":=").Lit(0))
+		encCodes = append(encCodes, Var().Err().Error())
+		switch {
+		case dynamic:
+			calcCodes = append(calcCodes, countCodes...)
+			calcCodes = append(calcCodes, Id("size").Op("+=").Id(ptn.IdEncoder).Dot("CalcStructHeader").Call(Id("n")))
+			encCodes = append(encCodes, countCodes...)
+			encCodes = append(encCodes, Id("offset").Op("=").Id(ptn.IdEncoder).Dot("WriteStructHeaderAsMap").Call(Id("n"), Id("offset")))
+		case asArrayLayout:
+			calcCodes = append(calcCodes, calcStruct)
+			encCodes = append(encCodes, encStructArray)
+		default:
+			calcCodes = append(calcCodes, calcStruct)
+			encCodes = append(encCodes, encStructMap)
+		}
 
-		decMapCodeSwitchCases = append(decMapCodeSwitchCases, Case(Lit(field.Tag)).Block(
-			append(dMap, Id("count").Op("++"))...,
-		// dMap...,
-		),
-		)
-	}
+		// nesting is limited by the decoder, e.g. for recursive types
+		decCodes = append(decCodes, If(Err().Op(":=").Id(ptn.IdDecoder).Dot("Enter").Call(Id("offset")), Err().Op("!=").Nil()).Block(
+			createDecodeErrReturnCode(structPath),
+		))
 
-	// not use jump offset
-	//decMapCodeSwitchCases = append(decMapCodeSwitchCases, Default().Block(
-	//	Id("offset").Op("=").Id(ptn.IdDecoder).Dot("JumpOffset").Call(Id("offset")),
-	//	),
-	//)
-	decMapCodeSwitchCases = append(decMapCodeSwitchCases, Default().Block(
-		Return(Lit(0), Qual("fmt", "Errorf").Call(Lit("unknown key[%s] found"), Id("s"))),
-	),
-	)
+		// keys in map layout may be missing or unknown, and so may be elements of evolvable arrays
+		evolvable := asArrayLayout && st.Evolvable
+		if asArrayLayout && !evolvable {
+			decCodes = append(decCodes, List(Id("offset"), Err()).Op(":=").Id(ptn.IdDecoder).Dot("CheckStructHeader").Call(Lit(len(order)), Id("offset")))
+		} else {
+			decCodes = append(decCodes, List(Id("n"), Id("offset"), Err()).Op(":=").Id(ptn.IdDecoder).Dot("ReadStructHeader").Call(Id("offset")))
+		}
+		decCodes = append(decCodes, If(Err().Op("!=").Nil()).Block(
+			createDecodeErrReturnCode(structPath),
+		))
+
+		decMapCodeSwitchCases := make([]Code, 0)
+		for slot := 0; slot < len(order); slot++ {
+			i := order[slot]
+			// gap between slots
+			if i < 0 {
+				gap := 1
+				for slot+gap < len(order) && order[slot+gap] < 0 {
+					gap++
+				}
+				cs, es, ds := gapCodes(slot, gap, evolvable, structPath)
+				calcCodes = append(calcCodes, cs...)
+				encCodes = append(encCodes, es...)
+				decCodes = append(decCodes, ds...)
+				slot += gap - 1
+				continue
+			}
+
+			field, fc := st.Fields[i], fieldCodes[i]
+			cs, es, ds := fc.cMap, fc.eMap, fc.dMap
+			if asArray {
+				cs, es, ds = fc.cArray, fc.eArray, fc.dArray
+			}
+
+			var calcFieldCodes, encFieldCodes []Code
+			if !asArrayLayout {
+				calcKeyStringCode, writeKeyStringCode := st.createKeyStringCode(field.Tag)
+				calcFieldCodes = append(calcFieldCodes, calcKeyStringCode)
+				encFieldCodes = append(encFieldCodes, writeKeyStringCode)
+			}
+			calcFieldCodes = append(calcFieldCodes, cs...)
+			encFieldCodes = append(encFieldCodes, es...)
+
+			if notEmpties[i] != nil {
+				calcCodes = append(calcCodes, If(notEmpties[i]).Block(calcFieldCodes...))
+				encCodes = append(encCodes, If(notEmpties[i]).Block(encFieldCodes...))
+			} else {
+				calcCodes = append(calcCodes, calcFieldCodes...)
+				encCodes = append(encCodes, encFieldCodes...)
+			}
+
+			switch {
+			case evolvable:
+				decCodes = append(decCodes, If(Id("n").Op(">").Lit(slot)).Block(ds...))
+			case asArrayLayout:
+				decCodes = append(decCodes, ds...)
+			default:
+				decMapCodeSwitchCases = append(decMapCodeSwitchCases, Case(Lit(field.Tag)).Block(ds...))
+			}
+		}
+		if evolvable {
+			// elements added by newer writers are skipped
+			decCodes = append(decCodes, For(Id("i").Op(":=").Lit(len(order)), Id("i").Op("<").Id("n"), Id("i").Op("++")).Block(
+				jumpOffsetCodes(structPath)...,
+			))
+		}
+		if asArrayLayout {
+			return
+		}
 
-	decMapCodes := make([]Code, 0)
-	decMapCodes = append(decMapCodes, List(Id("offset"), Err()).Op(":=").Id(ptn.IdDecoder).Dot("CheckStructHeader").Call(Lit(len(st.Fields)), Id("offset")))
-	decMapCodes = append(decMapCodes, If(Err().Op("!=").Nil()).Block(
-		Return(Lit(0), Err()),
-	))
-	//decMapCodes = append(decMapCodes, Id("dataLen").Op(":=").Id(ptn.IdDecoder).Dot("Len").Call())
-	//decMapCodes = append(decMapCodes, For(Id("count").Op("<").Id("dataLen").Block(
-	decMapCodes = append(decMapCodes, Id("count").Op(":=").Lit(0))
-	decMapCodes = append(decMapCodes, For(Id("count").Op("<").Lit(len(st.Fields)).Block(
-		Var().Id("s").String(),
-		List(Id("s"), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot("AsString").Call(Id("offset")),
-		If(Err().Op("!=").Nil()).Block(
-			Return(Lit(0), Err()),
-		),
-		Switch(Id("s")).Block(
-			decMapCodeSwitchCases...,
-		),
-	)))
+		// values of unknown keys are skipped
+		decMapCodeSwitchCases = append(decMapCodeSwitchCases, Default().Block(jumpOffsetCodes(structPath)...))
+
+		decCodes = append(decCodes, For(Id("i").Op(":=").Lit(0), Id("i").Op("<").Id("n"), Id("i").Op("++")).Block(
+			Var().Id("s").String(),
+			List(Id("s"), Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot("AsString").Call(Id("offset")),
+			If(Err().Op("!=").Nil()).Block(
+				createDecodeErrReturnCode(structPath),
+			),
+			Switch(Id("s")).Block(
+				decMapCodeSwitchCases...,
+			),
+		))
+		return
+	}
+
+	calcArraySizeCodes, encArrayCodes, decArrayCodes := createModeCode(true)
+	calcMapSizeCodes, encMapCodes, decMapCodes := createModeCode(false)
 
 	var firstEncParam, firstDecParam *Statement
-	if st.NoUseQual {
+	if st.HasTypeCode() {
+		firstEncParam = Id(v).Add(st.TypeCode)
+		firstDecParam = Id(v).Op("*").Add(st.TypeCode)
+	} else if st.NoUseQual {
 		firstEncParam = Id(v).Id(st.Name)
 		firstDecParam = Id(v).Op("*").Id(st.Name)
 	} else {
@@ -170,16 +306,87 @@ func (st *Structure) CreateCode(f *File) {
 
 	f.Comment(fmt.Sprintf("// decode to %s.%s\n", st.ImportPath, st.Name)).
 		Func().Id(st.DecodeArrayFuncName()).Params(firstDecParam, Id(ptn.IdDecoder).Op("*").Qual(ptn.PkDec, "Decoder"), Id("offset").Int()).Params(Int(), Error()).Block(
-		append(decArrayCodes, Return(Id("offset"), Err()))...,
+		append(decArrayCodes, Id(ptn.IdDecoder).Dot("Leave").Call(), Return(Id("offset"), Err()))...,
 	)
 
 	f.Comment(fmt.Sprintf("// decode to %s.%s\n", st.ImportPath, st.Name)).
 		Func().Id(st.DecodeMapFuncName()).Params(firstDecParam, Id(ptn.IdDecoder).Op("*").Qual(ptn.PkDec, "Decoder"), Id("offset").Int()).Params(Int(), Error()).Block(
 
-		append(decMapCodes, Return(Id("offset"), Err()))...,
+		append(decMapCodes, Id(ptn.IdDecoder).Dot("Leave").Call(), Return(Id("offset"), Err()))...,
 	)
 }
 
+// gapCodes writes nil to the empty slots from slot, and skips them in decoding.
+// gaps of more than one slot are written in loops not to grow generated code.
+func gapCodes(slot, gap int, evolvable bool, path decodePath) (calcCodes, encCodes, decCodes []Code) {
+	if gap == 1 {
+		calcCodes = []Code{Id("size").Op("+=").Id(ptn.IdEncoder).Dot("CalcNil").Call()}
+		encCodes = []Code{Id("offset").Op("=").Id(ptn.IdEncoder).Dot("WriteNil").Call(Id("offset"))}
+		if evolvable {
+			decCodes = []Code{If(Id("n").Op(">").Lit(slot)).Block(jumpOffsetCodes(path)...)}
+		} else {
+			decCodes = jumpOffsetCodes(path)
+		}
+		return
+	}
+
+	calcCodes = []Code{Id("size").Op("+=").Lit(gap).Op("*").Id(ptn.IdEncoder).Dot("CalcNil").Call()}
+	encCodes = []Code{For(Id("i").Op(":=").Lit(0), Id("i").Op("<").Lit(gap), Id("i").Op("++")).Block(
+		Id("offset").Op("=").Id(ptn.IdEncoder).Dot("WriteNil").Call(Id("offset")),
+	)}
+	cond := Id("i").Op("<").Lit(slot + gap)
+	if evolvable {
+		cond = cond.Op("&&").Id("i").Op("<").Id("n")
+	}
+	decCodes = []Code{For(Id("i").Op(":=").Lit(slot), cond, Id("i").Op("++")).Block(
+		jumpOffsetCodes(path)...,
+	)}
+	return
+}
+
+// jumpOffsetCodes skips the value at offset.
+func jumpOffsetCodes(path decodePath) []Code {
+	return []Code{
+		List(Id("offset"), Err()).Op("=").Id(ptn.IdDecoder).Dot("JumpOffset").Call(Id("offset")),
+		If(Err().Op("!=").Nil()).Block(
+			createDecodeErrReturnCode(path),
+		),
+	}
+}
+
+// CanOmitEmpty returns whether the field of node can be left out when empty.
+// structs except time.Time and ext types have no empty value to check.
+func CanOmitEmpty(node *Node) bool {
+	empty, _ := emptyCode(node, "")
+	return empty != nil
+}
+
+// emptyCode returns the conditions that the field is empty and not, or nil when the field can not be omitted.
+func emptyCode(node *Node, fieldName string) (empty Code, notEmpty Code) {
+	switch {
+	case node.IsIdentical():
+		switch node.IdenticalName {
+		case "bool":
+			return Op("!").Id(fieldName), Id(fieldName)
+		case "string":
+			return Id(fieldName).Op("==").Lit(""), Id(fieldName).Op("!=").Lit("")
+		}
+		return Id(fieldName).Op("==").Lit(0), Id(fieldName).Op("!=").Lit(0)
+
+	case node.IsSlice(), node.IsArray(), node.IsMap():
+		return Len(Id(fieldName)).Op("==").Lit(0), Len(Id(fieldName)).Op(">").Lit(0)
+
+	case node.IsPointer(), node.IsInterface():
+		return Id(fieldName).Op("==").Nil(), Id(fieldName).Op("!=").Nil()
+
+	case node.IsStruct():
+		if node.ImportPath == "time" && node.StructName == "Time" {
+			return Id(fieldName).Dot("IsZero").Call(), Op("!").Id(fieldName).Dot("IsZero").Call()
+		}
+	}
+	return nil, nil
+}
+
 func (st *Structure) createStructCode(fieldNum int) (Code, Code, Code) {
 
 	suffix := ""
@@ -213,30 +420,36 @@ func (st *Structure) createKeyStringCode(v string) (Code, Code) {
 		Id("offset").Op("=").Id(ptn.IdEncoder).Dot("WriteString"+suffix).Call(Lit(v), Lit(l), Id("offset"))
 }
 
-func (st *Structure) createFieldCode(node *Node, encodeFieldName, decodeFieldName string) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
+func (st *Structure) createFieldCode(node *Node, encodeFieldName, decodeFieldName string, path decodePath) (cArray []Code, cMap []Code, eArray []Code, eMap []Code, dArray []Code, dMap []Code) {
 
 	switch {
 	case node.IsIdentical():
-		return st.createIdentCode(node, encodeFieldName, decodeFieldName)
+		return st.createIdentCode(node, encodeFieldName, decodeFieldName, path)
+
+	case node.IsInterface():
+		return st.createInterfaceCode(node, encodeFieldName, decodeFieldName, path)
+
+	case node.IsExt():
+		return st.createExtCode(node, encodeFieldName, decodeFieldName, path)
 
 	case node.IsSlice():
-		return st.createSliceCode(node, encodeFieldName, decodeFieldName)
+		return st.createSliceCode(node, encodeFieldName, decodeFieldName, path)
 
 	case node.IsArray():
-		return st.createArrayCode(node, encodeFieldName, decodeFieldName)
+		return st.createArrayCode(node, encodeFieldName, decodeFieldName, path)
 
 	case node.IsMap():
-		return st.createMapCode(node, encodeFieldName, decodeFieldName)
+		return st.createMapCode(node, encodeFieldName, decodeFieldName, path)
 
 	case node.IsPointer():
-		return st.createPointerCode(node, encodeFieldName, decodeFieldName)
+		return st.createPointerCode(node, encodeFieldName, decodeFieldName, path)
 
 	case node.IsStruct():
 
 		if node.ImportPath == "time" {
-			return st.createTimeCode(encodeFieldName, decodeFieldName, node)
+			return st.createTimeCode(encodeFieldName, decodeFieldName, node, path)
 		} else {
-			return st.createNamedCode(encodeFieldName, decodeFieldName, node)
+			return st.createNamedCode(encodeFieldName, decodeFieldName, node, path)
 		}
 	}
 
@@ -0,0 +1,278 @@
+package generator
+
+import (
+	"fmt"
+	"go/types"
+	"strconv"
+	"strings"
+
+	. "github.com/dave/jennifer/jen"
+)
+
+// typeCode creates the code of t written in the output package.
+func (g *generator) typeCode(t types.Type) (Code, error) {
+	switch typ := types.Unalias(t).(type) {
+	case *types.Basic:
+		if typ.Kind() == types.UnsafePointer {
+			return Qual("unsafe", "Pointer"), nil
+		}
+		return Id(typ.Name()), nil
+
+	case *types.Named:
+		obj := typ.Obj()
+		var code *Statement
+		if obj.Pkg() == nil || obj.Pkg().Path() == g.outputImportPath {
+			code = Id(obj.Name())
+		} else if obj.Exported() {
+			code = Qual(obj.Pkg().Path(), obj.Name())
+		} else {
+			return nil, fmt.Errorf("type %s is not exported", typ.String())
+		}
+
+		if typ.TypeArgs().Len() < 1 {
+			return code, nil
+		}
+		args := make([]Code, 0, typ.TypeArgs().Len())
+		for i := 0; i < typ.TypeArgs().Len(); i++ {
+			arg, err := g.typeCode(typ.TypeArgs().At(i))
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		return code.Index(List(args...)), nil
+
+	case *types.Pointer:
+		elem, err := g.typeCode(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Op("*").Add(elem), nil
+
+	case *types.Slice:
+		elem, err := g.typeCode(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Index().Add(elem), nil
+
+	case *types.Array:
+		elem, err := g.typeCode(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Index(Lit(int(typ.Len()))).Add(elem), nil
+
+	case *types.Map:
+		key, err := g.typeCode(typ.Key())
+		if err != nil {
+			return nil, err
+		}
+		elem, err := g.typeCode(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return Map(key).Add(elem), nil
+
+	case *types.Chan:
+		elem, err := g.typeCode(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		switch typ.Dir() {
+		case types.SendOnly:
+			return Chan().Op("<-").Add(elem), nil
+		case types.RecvOnly:
+			return Op("<-").Chan().Add(elem), nil
+		}
+		return Chan().Add(elem), nil
+
+	case *types.Signature:
+		params, err := g.tupleCode(typ.Params(), typ.Variadic())
+		if err != nil {
+			return nil, err
+		}
+		results, err := g.tupleCode(typ.Results(), false)
+		if err != nil {
+			return nil, err
+		}
+		return Func().Params(params...).Params(results...), nil
+
+	case *types.Interface:
+		var methods []Code
+		for i := 0; i < typ.NumEmbeddeds(); i++ {
+			embedded, err := g.typeCode(typ.EmbeddedType(i))
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, embedded)
+		}
+		for i := 0; i < typ.NumExplicitMethods(); i++ {
+			method := typ.ExplicitMethod(i)
+			if !method.Exported() && method.Pkg().Path() != g.outputImportPath {
+				return nil, fmt.Errorf("interface with unexported method %s is not supported", method.Name())
+			}
+			sig := method.Type().(*types.Signature)
+			params, err := g.tupleCode(sig.Params(), sig.Variadic())
+			if err != nil {
+				return nil, err
+			}
+			results, err := g.tupleCode(sig.Results(), false)
+			if err != nil {
+				return nil, err
+			}
+			methods = append(methods, Id(method.Name()).Params(params...).Params(results...))
+		}
+		return Interface(methods...), nil
+
+	case *types.Struct:
+		var fields []Code
+		for i := 0; i < typ.NumFields(); i++ {
+			field := typ.Field(i)
+			if !field.Exported() && field.Pkg().Path() != g.outputImportPath {
+				return nil, fmt.Errorf("anonymous struct with unexported field %s is not supported", field.Name())
+			}
+			fieldType, err := g.typeCode(field.Type())
+			if err != nil {
+				return nil, err
+			}
+
+			code := Id(field.Name()).Add(fieldType)
+			if field.Embedded() {
+				// the name of embedded alias is the alias name
+				if alias, ok := field.Type().(*types.Alias); ok {
+					fieldType, err = g.aliasCode(alias)
+					if err != nil {
+						return nil, err
+					}
+				}
+				code = Add(fieldType)
+			}
+			if tag := typ.Tag(i); tag != "" {
+				code = code.Add(Lit(tag))
+			}
+			fields = append(fields, code)
+		}
+		return Struct(fields...), nil
+	}
+	return nil, fmt.Errorf("type %s is not supported", t.String())
+}
+
+func (g *generator) aliasCode(alias *types.Alias) (Code, error) {
+	obj := alias.Obj()
+	switch {
+	case obj.Pkg() == nil || obj.Pkg().Path() == g.outputImportPath:
+		return Id(obj.Name()), nil
+	case obj.Exported():
+		return Qual(obj.Pkg().Path(), obj.Name()), nil
+	}
+	return nil, fmt.Errorf("type %s is not exported", alias.String())
+}
+
+func (g *generator) tupleCode(tuple *types.Tuple, variadic bool) ([]Code, error) {
+	codes := make([]Code, 0, tuple.Len())
+	for i := 0; i < tuple.Len(); i++ {
+		t := tuple.At(i).Type()
+		if variadic && i == tuple.Len()-1 {
+			elem, err := g.typeCode(t.(*types.Slice).Elem())
+			if err != nil {
+				return nil, err
+			}
+			codes = append(codes, Op("...").Add(elem))
+			continue
+		}
+		code, err := g.typeCode(t)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// qualifier writes types in the output package without the package name.
+func (g *generator) qualifier(p *types.Package) string {
+	if p.Path() == g.outputImportPath {
+		return ""
+	}
+	return p.Name()
+}
+
+func pathQualifier(p *types.Package) string {
+	return p.Path()
+}
+
+// typeKey returns the string of t that identical types share, even if they are written with aliases.
+func typeKey(t types.Type) string {
+	var b strings.Builder
+	writeTypeKey(&b, t)
+	return b.String()
+}
+
+func writeTypeKey(b *strings.Builder, t types.Type) {
+	switch typ := types.Unalias(t).(type) {
+	case *types.Named:
+		b.WriteString(types.TypeString(typ.Origin(), pathQualifier))
+		if typ.TypeArgs().Len() > 0 {
+			b.WriteString("[")
+			for i := 0; i < typ.TypeArgs().Len(); i++ {
+				if i > 0 {
+					b.WriteString(", ")
+				}
+				writeTypeKey(b, typ.TypeArgs().At(i))
+			}
+			b.WriteString("]")
+		}
+
+	case *types.Pointer:
+		b.WriteString("*")
+		writeTypeKey(b, typ.Elem())
+
+	case *types.Slice:
+		b.WriteString("[]")
+		writeTypeKey(b, typ.Elem())
+
+	case *types.Array:
+		b.WriteString("[" + strconv.FormatInt(typ.Len(), 10) + "]")
+		writeTypeKey(b, typ.Elem())
+
+	case *types.Map:
+		b.WriteString("map[")
+		writeTypeKey(b, typ.Key())
+		b.WriteString("]")
+		writeTypeKey(b, typ.Elem())
+
+	case *types.Chan:
+		switch typ.Dir() {
+		case types.SendOnly:
+			b.WriteString("chan<- ")
+		case types.RecvOnly:
+			b.WriteString("<-chan ")
+		default:
+			b.WriteString("chan ")
+		}
+		writeTypeKey(b, typ.Elem())
+
+	case *types.Struct:
+		b.WriteString("struct{")
+		for i := 0; i < typ.NumFields(); i++ {
+			if i > 0 {
+				b.WriteString("; ")
+			}
+			field := typ.Field(i)
+			if field.Embedded() {
+				b.WriteString("embedded ")
+			}
+			b.WriteString(field.Name() + " ")
+			writeTypeKey(b, field.Type())
+			if tag := typ.Tag(i); tag != "" {
+				b.WriteString(" " + strconv.Quote(tag))
+			}
+		}
+		b.WriteString("}")
+
+	default:
+		// basic, function and interface types
+		b.WriteString(types.TypeString(typ, pathQualifier))
+	}
+}
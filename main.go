@@ -5,15 +5,27 @@ import (
 	"log"
 
 	"github.com/shamaton/msgpackgen/internal/generator"
+	"github.com/shamaton/msgpackgen/msgpack/enc"
 )
 
 var (
-	input    = flag.String("i", ".", "input directory")
-	output   = flag.String("o", ".", "output directory")
-	filename = flag.String("g", defaultFileName, "generated file name")
-	pointer  = flag.Int("p", defaultPointerLevel, "pointer level to consider")
-	strict   = flag.Bool("s", false, "strict mode")
-	verbose  = flag.Bool("v", false, "verbose diagnostics")
+	input     = flag.String("i", ".", "input directory")
+	output    = flag.String("o", ".", "output directory")
+	filename  = flag.String("g", defaultFileName, "generated file name")
+	pointer   = flag.Int("p", defaultPointerLevel, "pointer level to consider")
+	strict    = flag.Bool("s", false, "strict mode")
+	verbose   = flag.Bool("v", false, "verbose diagnostics")
+	inline    = flag.Bool("inline", false, "inline embedded structs without msgpack tag name")
+	evolvable = flag.Bool("evolvable", false, "accept arrays shorter or longer than struct fields on decode")
+	register  = flag.Bool("init", false, "register the generated resolver in init")
+
+	complexType = flag.Int("complex-ext", int(enc.DefaultComplexTypeCode), "ext type of complex64 and complex128")
+
+	includePackage = flag.String("include-pkg", "", "regexp of import paths of packages to generate")
+	excludePackage = flag.String("exclude-pkg", "", "regexp of import paths of packages not to generate")
+	includeType    = flag.String("include-type", "", "regexp of type names to generate")
+	excludeType    = flag.String("exclude-type", "", "regexp of type names not to generate")
+	nonRecursive   = flag.Bool("nonrecursive", false, "read only the input directory, not subdirectories")
 )
 
 const (
@@ -25,7 +37,25 @@ func main() {
 
 	flag.Parse()
 
-	err := generator.Run(*input, *output, *filename, *pointer, *strict, *verbose)
+	err := generator.Run(generator.Config{
+		Input:       *input,
+		Output:      *output,
+		FileName:    *filename,
+		Pointer:     *pointer,
+		Strict:      *strict,
+		Verbose:     *verbose,
+		Inline:      *inline,
+		Evolvable:   *evolvable,
+		Register:    *register,
+		ComplexType: complexType,
+		Filter: generator.Filter{
+			IncludePackage: *includePackage,
+			ExcludePackage: *excludePackage,
+			IncludeType:    *includeType,
+			ExcludeType:    *excludeType,
+			NonRecursive:   *nonRecursive,
+		},
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
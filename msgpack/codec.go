@@ -0,0 +1,227 @@
+package msgpack
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/shamaton/msgpack"
+	"github.com/shamaton/msgpackgen/msgpack/dec"
+	"github.com/shamaton/msgpackgen/msgpack/enc"
+)
+
+// ErrNotResolved is returned for types not resolved by generated code, when the fallback is disabled.
+var ErrNotResolved = errors.New("msgpackgen : not resolved by generated code")
+
+// Options configure encoding and decoding per call, instead of the package settings.
+type Options struct {
+	// structs are encoded and decoded as arrays instead of maps
+	StructAsArray bool
+	// limits of generated decoders. nil uses the limits set by SetDecodeLimits.
+	Limits *dec.Limits
+	// time.Time values are decoded in UTC instead of the local time zone by generated decoders
+	DecodedTimeAsUTC bool
+	// types not resolved by generated code return ErrNotResolved, instead of being processed by shamaton/msgpack
+	DisableFallback bool
+}
+
+// DefaultOptions returns the options of Encode and Decode, which follow the package settings.
+func DefaultOptions() Options {
+	return Options{StructAsArray: StructAsArray()}
+}
+
+// Codec encodes and decodes with the options bound to it.
+// it is safe for concurrent use.
+type Codec struct {
+	opts Options
+	// encodes structs in interface{} values with c
+	structEncoder enc.StructEncoder
+}
+
+// NewCodec returns a new Codec with o.
+func NewCodec(o Options) *Codec {
+	c := &Codec{opts: o}
+	c.structEncoder = c.encode
+	return c
+}
+
+// codecs of the default options by the layout, which are shared to encode without allocation
+var defaultCodecs = [2]*Codec{NewCodec(Options{}), NewCodec(Options{StructAsArray: true})}
+
+func defaultCodec() *Codec {
+	if StructAsArray() {
+		return defaultCodecs[1]
+	}
+	return defaultCodecs[0]
+}
+
+// encoders are reused, because generated resolvers keep them from being allocated on the stack
+var encoderPool = sync.Pool{New: func() interface{} { return enc.NewEncoder() }}
+
+// Options returns the options of c.
+func (c *Codec) Options() Options {
+	return c.opts
+}
+
+// EncodeWithOptions returns the MessagePack-encoded byte array of v with o.
+func EncodeWithOptions(v interface{}, o Options) ([]byte, error) {
+	return NewCodec(o).Encode(v)
+}
+
+// AppendEncodeWithOptions appends the MessagePack-encoded v to dst with o.
+func AppendEncodeWithOptions(dst []byte, v interface{}, o Options) ([]byte, error) {
+	return NewCodec(o).AppendEncode(dst, v)
+}
+
+// DecodeWithOptions decodes data to the pointer of v with o.
+func DecodeWithOptions(data []byte, v interface{}, o Options) error {
+	return NewCodec(o).Decode(data, v)
+}
+
+// EncodeWithResolver encodes v with r by the package settings.
+// the methods generated on types use it, so that they follow the settings without reading them.
+func EncodeWithResolver(v interface{}, r *Resolver) ([]byte, error) {
+	return defaultCodec().encodeWith(v, r)
+}
+
+// DecodeWithResolver decodes data to the pointer of v with r by the package settings.
+func DecodeWithResolver(data []byte, v interface{}, r *Resolver) error {
+	return defaultCodec().decodeWithResolver(data, v, r)
+}
+
+// Encode returns the MessagePack-encoded byte array of v.
+func (c *Codec) Encode(v interface{}) ([]byte, error) {
+	return c.encode(v, c.opts.StructAsArray)
+}
+
+// AppendEncode appends the MessagePack-encoded v to dst and returns the extended slice.
+func (c *Codec) AppendEncode(dst []byte, v interface{}) ([]byte, error) {
+	return c.appendEncode(dst, v, c.opts.StructAsArray)
+}
+
+// Decode analyzes the MessagePack-encoded data and stores
+// the result into the pointer of v.
+func (c *Codec) Decode(data []byte, v interface{}) error {
+	return c.decode(data, v, c.opts.StructAsArray)
+}
+
+func (c *Codec) encode(v interface{}, asArray bool) ([]byte, error) {
+	return c.appendEncode(nil, v, asArray)
+}
+
+func (c *Codec) appendEncode(dst []byte, v interface{}, asArray bool) ([]byte, error) {
+	e := c.getEncoder()
+	defer putEncoder(e)
+	for _, r := range resolvers(v) {
+		appendFunc, encodeFunc := r.AppendAsMap, r.EncodeAsMap
+		if asArray {
+			appendFunc, encodeFunc = r.AppendAsArray, r.EncodeAsArray
+		}
+		if b, err := r.appendEncode(e, dst, v, appendFunc, encodeFunc); err != nil {
+			return nil, err
+		} else if b != nil {
+			return b, nil
+		}
+	}
+
+	if c.opts.DisableFallback {
+		return nil, c.notResolved(v)
+	}
+	var b []byte
+	var err error
+	if asArray {
+		b, err = msgpack.EncodeStructAsArray(v)
+	} else {
+		b, err = msgpack.EncodeStructAsMap(v)
+	}
+	if err != nil || dst == nil {
+		return b, err
+	}
+	return append(dst, b...), nil
+}
+
+// encodeWith encodes v with r by the layout of c.
+func (c *Codec) encodeWith(v interface{}, r *Resolver) ([]byte, error) {
+	appendFunc, encodeFunc := r.AppendAsMap, r.EncodeAsMap
+	if c.opts.StructAsArray {
+		appendFunc, encodeFunc = r.AppendAsArray, r.EncodeAsArray
+	}
+	e := c.getEncoder()
+	defer putEncoder(e)
+	b, err := r.appendEncode(e, nil, v, appendFunc, encodeFunc)
+	if err == nil && b == nil {
+		return nil, c.notResolved(v)
+	}
+	return b, err
+}
+
+func (c *Codec) decode(data []byte, v interface{}, asArray bool) error {
+	return c.decodeWith(c.newDecoder(data), v, asArray)
+}
+
+// decodeWith decodes the data of d, which may be validated already.
+func (c *Codec) decodeWith(d *dec.Decoder, v interface{}, asArray bool) error {
+	for _, r := range resolvers(v) {
+		decode := r.DecodeAsMap
+		if asArray {
+			decode = r.DecodeAsArray
+		}
+		d.SetComplexTypeCode(r.complexTypeCode())
+		b, err := decode(d, v)
+		if err != nil {
+			return err
+		}
+		if b {
+			return nil
+		}
+	}
+
+	if c.opts.DisableFallback {
+		return c.notResolved(v)
+	}
+	if asArray {
+		return msgpack.DecodeStructAsArray(d.Data(), v)
+	}
+	return msgpack.DecodeStructAsMap(d.Data(), v)
+}
+
+// decodeWithResolver decodes data to v with r by the layout of c.
+func (c *Codec) decodeWithResolver(data []byte, v interface{}, r *Resolver) error {
+	decode := r.DecodeAsMap
+	if c.opts.StructAsArray {
+		decode = r.DecodeAsArray
+	}
+	d := c.newDecoder(data)
+	d.SetComplexTypeCode(r.complexTypeCode())
+	b, err := decode(d, v)
+	if err == nil && !b {
+		return c.notResolved(v)
+	}
+	return err
+}
+
+// getEncoder returns an encoder which encodes structs in interface{} values with c.
+func (c *Codec) getEncoder() *enc.Encoder {
+	e := encoderPool.Get().(*enc.Encoder)
+	e.SetStructEncoder(c.structEncoder)
+	return e
+}
+
+func putEncoder(e *enc.Encoder) {
+	e.Reset()
+	encoderPool.Put(e)
+}
+
+// newDecoder returns a decoder of data with the options of c.
+func (c *Codec) newDecoder(data []byte) *dec.Decoder {
+	d := dec.NewDecoder(data)
+	if c.opts.Limits != nil {
+		d.SetLimits(*c.opts.Limits)
+	}
+	d.SetDecodedTimeAsUTC(c.opts.DecodedTimeAsUTC)
+	return d
+}
+
+func (c *Codec) notResolved(v interface{}) error {
+	return fmt.Errorf("%w : %T", ErrNotResolved, v)
+}
@@ -5,8 +5,10 @@ import (
 )
 
 func (d *Decoder) AsBool(offset int) (bool, int, error) {
-	code := d.data[offset]
-	offset++
+	code, offset, err := d.readSize1(offset)
+	if err != nil {
+		return false, 0, err
+	}
 
 	switch code {
 	case def.True:
@@ -14,5 +16,5 @@ func (d *Decoder) AsBool(offset int) (bool, int, error) {
 	case def.False:
 		return false, offset, nil
 	}
-	return false, 0, d.errorTemplate(code, "AsBool")
+	return false, 0, d.errorTemplate(code, offset-def.Byte1, "bool")
 }
@@ -6,45 +6,73 @@ import (
 	"math"
 
 	"github.com/shamaton/msgpack/def"
+	"github.com/shamaton/msgpackgen/msgpack/enc"
 )
 
-// todo : def
-const complexType = -128
+var complexTypeCode = enc.DefaultComplexTypeCode
+
+// SetComplexTypeCode sets the ext type of complex64 and complex128 of decoders created after.
+// the type of timestamp and ext types set by SetExtDecoder can not be used.
+func SetComplexTypeCode(code int8) error {
+	if code == def.TimeStamp {
+		return fmt.Errorf("msgpackgen : ext type %d is reserved for timestamp", code)
+	}
+	if extDecoders[uint8(code)] != nil {
+		return fmt.Errorf("msgpackgen : ext type %d is set to an ext decoder", code)
+	}
+	complexTypeCode = code
+	return nil
+}
+
+// SetComplexTypeCode sets the ext type of complex64 and complex128 read by d.
+func (d *Decoder) SetComplexTypeCode(code int8) {
+	d.complexTypeCode = code
+}
 
 func (d *Decoder) AsComplex64(offset int) (complex64, int, error) {
-	code, offset := d.readSize1(offset)
+	code, offset, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	switch code {
 	case def.Fixext8:
-		t, offset := d.readSize1(offset)
-		if int8(t) != complexType {
-			return 0, 0, fmt.Errorf("fixext8. complex type is diffrent %d, %d", t, complexType)
+		// type, real and imaginary
+		bs, offset, err := d.readSizeN(offset, def.Byte1+def.Byte4+def.Byte4)
+		if err != nil {
+			return 0, 0, err
+		}
+		if t := int8(bs[0]); t != d.complexTypeCode {
+			return 0, 0, fmt.Errorf("fixext8. complex type is diffrent %d, %d", t, d.complexTypeCode)
 		}
-		rb, offset := d.readSize4(offset)
-		ib, offset := d.readSize4(offset)
-		r := math.Float32frombits(binary.BigEndian.Uint32(rb))
-		i := math.Float32frombits(binary.BigEndian.Uint32(ib))
+		r := math.Float32frombits(binary.BigEndian.Uint32(bs[1:5]))
+		i := math.Float32frombits(binary.BigEndian.Uint32(bs[5:9]))
 		return complex(r, i), offset, nil
 	}
 
-	return 0, 0, d.errorTemplate(code, "AsComplex64")
+	return 0, 0, d.errorTemplate(code, offset-def.Byte1, "complex64")
 }
 
 func (d *Decoder) AsComplex128(offset int) (complex128, int, error) {
-	code, offset := d.readSize1(offset)
+	code, offset, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	switch code {
 	case def.Fixext16:
-		t, offset := d.readSize1(offset)
-		if int8(t) != complexType {
-			return 0, 0, fmt.Errorf("fixext16. complex type is diffrent %d, %d", t, complexType)
+		// type, real and imaginary
+		bs, offset, err := d.readSizeN(offset, def.Byte1+def.Byte8+def.Byte8)
+		if err != nil {
+			return 0, 0, err
+		}
+		if t := int8(bs[0]); t != d.complexTypeCode {
+			return 0, 0, fmt.Errorf("fixext16. complex type is diffrent %d, %d", t, d.complexTypeCode)
 		}
-		rb, offset := d.readSize8(offset)
-		ib, offset := d.readSize8(offset)
-		r := math.Float64frombits(binary.BigEndian.Uint64(rb))
-		i := math.Float64frombits(binary.BigEndian.Uint64(ib))
+		r := math.Float64frombits(binary.BigEndian.Uint64(bs[1:9]))
+		i := math.Float64frombits(binary.BigEndian.Uint64(bs[9:17]))
 		return complex(r, i), offset, nil
 	}
 
-	return 0, 0, d.errorTemplate(code, "AsComplex128")
+	return 0, 0, d.errorTemplate(code, offset-def.Byte1, "complex128")
 }
@@ -1,19 +1,28 @@
 package dec
 
-import (
-	"fmt"
-)
-
 type Decoder struct {
 	data []byte
+
+	limits    Limits
+	allocated int
+	depth     int
+
+	// time.Time values are decoded in UTC instead of the local time zone
+	timeAsUTC bool
+	// ext type of complex64 and complex128
+	complexTypeCode int8
 }
 
 func NewDecoder(data []byte) *Decoder {
-	return &Decoder{data: data}
+	return &Decoder{data: data, limits: defaultLimits, complexTypeCode: complexTypeCode}
 }
 
 func (d *Decoder) Len() int { return len(d.data) }
 
-func (d *Decoder) errorTemplate(code byte, str string) error {
-	return fmt.Errorf("msgpackgen : invalid code %x decoding %s", code, str)
+// Data returns the bytes decoded by d.
+func (d *Decoder) Data() []byte { return d.data }
+
+// SetDecodedTimeAsUTC sets whether d decodes time.Time values in UTC instead of the local time zone.
+func (d *Decoder) SetDecodedTimeAsUTC(on bool) {
+	d.timeAsUTC = on
 }
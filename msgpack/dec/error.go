@@ -0,0 +1,117 @@
+package dec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ShortBufferError is returned when data ends before a value is read.
+// it matches io.ErrUnexpectedEOF with errors.Is.
+type ShortBufferError struct {
+	// offset of the value
+	Offset int
+	// bytes required from Offset
+	Need int
+	// length of data
+	Len int
+}
+
+func (e *ShortBufferError) Error() string {
+	return fmt.Sprintf("msgpackgen : short buffer at offset %d, need %d bytes but data length is %d", e.Offset, e.Need, e.Len)
+}
+
+func (e *ShortBufferError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+func (d *Decoder) shortBufferError(offset, need int) error {
+	return &ShortBufferError{Offset: offset, Need: need, Len: len(d.data)}
+}
+
+// DecodeError is returned when a value can not be decoded.
+// the generated code adds the type and the path of the field while returning.
+type DecodeError struct {
+	// outermost type decoded by the generated code, e.g. Order
+	Type string
+	// path of the field in Type, e.g. Items[3].Price
+	Path string
+	// offset of the value
+	Offset int
+	// kind of the value expected at Offset, empty when the error is caused by Err
+	Expected string
+	// format code at Offset
+	Code byte
+	// cause of the error, e.g. ShortBufferError
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	var b strings.Builder
+	b.WriteString(errorPrefix)
+	if e.Type != "" {
+		b.WriteString("decoding ")
+		b.WriteString(e.Type)
+		if e.Path != "" {
+			b.WriteString(".")
+			b.WriteString(e.Path)
+		}
+		b.WriteString(" : ")
+	}
+	if e.Err != nil {
+		b.WriteString(strings.TrimPrefix(e.Err.Error(), errorPrefix))
+	} else {
+		fmt.Fprintf(&b, "invalid code %x at offset %d, expected %s", e.Code, e.Offset, e.Expected)
+	}
+	return b.String()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+const errorPrefix = "msgpackgen : "
+
+func (d *Decoder) errorTemplate(code byte, offset int, expected string) error {
+	return &DecodeError{Offset: offset, Expected: expected, Code: code}
+}
+
+// WrapError adds typ and the path of the field to err returned while decoding the field.
+// keys are indexes of slices and arrays, and keys of maps, from the outermost.
+func (d *Decoder) WrapError(err error, typ, field string, keys ...interface{}) error {
+	de, ok := err.(*DecodeError)
+	if !ok {
+		de = &DecodeError{Offset: -1, Err: err}
+		var sbe *ShortBufferError
+		var le *LimitError
+		switch {
+		case errors.As(err, &sbe):
+			de.Offset = sbe.Offset
+		case errors.As(err, &le):
+			de.Offset = le.Offset
+		}
+		if 0 <= de.Offset && de.Offset < len(d.data) {
+			de.Code = d.data[de.Offset]
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(field)
+	for _, k := range keys {
+		if s, ok := k.(string); ok {
+			fmt.Fprintf(&b, "[%q]", s)
+		} else {
+			fmt.Fprintf(&b, "[%v]", k)
+		}
+	}
+	// the path of the inner type follows
+	if b.Len() > 0 && de.Path != "" {
+		b.WriteString(".")
+	}
+	b.WriteString(de.Path)
+
+	de.Type = typ
+	de.Path = b.String()
+	return de
+}
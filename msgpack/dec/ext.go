@@ -0,0 +1,137 @@
+package dec
+
+import (
+	"fmt"
+
+	"github.com/shamaton/msgpack/def"
+)
+
+// ExtDecoder reads values of T from the data of an ext type.
+type ExtDecoder[T any] interface {
+	// ReadExt decodes data, which refers to the bytes being decoded and must be copied to be retained.
+	ReadExt(data []byte) (T, error)
+}
+
+type extDecoder struct {
+	// ExtDecoder[T]
+	coder interface{}
+	// for values in interface{}
+	asInterface func(data []byte) (interface{}, error)
+}
+
+// ext decoders are set before decoding, by the index of uint8(code)
+var extDecoders [256]*extDecoder
+
+// SetExtDecoder sets x to decode the ext type code to T.
+func SetExtDecoder[T any](code int8, x ExtDecoder[T]) {
+	extDecoders[uint8(code)] = &extDecoder{
+		coder: x,
+		asInterface: func(data []byte) (interface{}, error) {
+			return x.ReadExt(data)
+		},
+	}
+}
+
+// AsExt decodes the ext type code at offset to T.
+func AsExt[T any](d *Decoder, code int8, offset int) (T, int, error) {
+	var v T
+	x, ok := extDecoders[uint8(code)].decoder().(ExtDecoder[T])
+	if !ok {
+		return v, 0, fmt.Errorf("msgpackgen : ext type %d is not set for %T", code, v)
+	}
+
+	extType, data, o, err := d.readExt(offset)
+	if err != nil {
+		return v, 0, err
+	}
+	if extType != code {
+		return v, 0, d.extError(offset, fmt.Errorf("ext type is different %d, %d", extType, code))
+	}
+	if v, err = x.ReadExt(data); err != nil {
+		return v, 0, d.extError(offset, err)
+	}
+	return v, o, nil
+}
+
+func (x *extDecoder) decoder() interface{} {
+	if x == nil {
+		return nil
+	}
+	return x.coder
+}
+
+// asExtInterface decodes the ext format at offset with the decoder set to its type.
+func (d *Decoder) asExtInterface(offset int) (interface{}, int, error) {
+	extType, data, o, err := d.readExt(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	x := extDecoders[uint8(extType)]
+	if x == nil {
+		return nil, 0, d.extError(offset, fmt.Errorf("ext type %d is not set", extType))
+	}
+	v, err := x.asInterface(data)
+	if err != nil {
+		return nil, 0, d.extError(offset, err)
+	}
+	return v, o, nil
+}
+
+func (d *Decoder) extError(offset int, err error) error {
+	return &DecodeError{Offset: offset, Code: d.data[offset], Err: err}
+}
+
+func (d *Decoder) isExt(code byte) bool {
+	switch code {
+	case def.Fixext1, def.Fixext2, def.Fixext4, def.Fixext8, def.Fixext16, def.Ext8, def.Ext16, def.Ext32:
+		return true
+	}
+	return false
+}
+
+// readExt reads the ext format at offset and returns its type and data.
+func (d *Decoder) readExt(offset int) (int8, []byte, int, error) {
+	code, offset, err := d.readSize1(offset)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	var l int
+	switch code {
+	case def.Fixext1:
+		l = def.Byte1
+	case def.Fixext2:
+		l = def.Byte2
+	case def.Fixext4:
+		l = def.Byte4
+	case def.Fixext8:
+		l = def.Byte8
+	case def.Fixext16:
+		l = def.Byte16
+	case def.Ext8:
+		l, offset, err = d.readLength(offset, def.Byte1)
+	case def.Ext16:
+		l, offset, err = d.readLength(offset, def.Byte2)
+	case def.Ext32:
+		l, offset, err = d.readLength(offset, def.Byte4)
+	default:
+		return 0, nil, 0, d.errorTemplate(code, offset-def.Byte1, "ext")
+	}
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	// the type is followed by the data
+	if err = d.checkStringLength(offset+def.Byte1, l); err != nil {
+		return 0, nil, 0, err
+	}
+
+	extType, offset, err := d.readSize1(offset)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	data, offset, err := d.readSizeN(offset, l)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	return int8(extType), data, offset, nil
+}
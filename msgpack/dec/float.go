@@ -7,26 +7,31 @@ import (
 )
 
 func (d *Decoder) AsFloat32(offset int) (float32, int, error) {
-	code := d.data[offset]
+	code, _, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	switch {
 	case code == def.Float32:
-		offset++
-		bs, offset := d.readSize4(offset)
+		bs, offset, err := d.readSize4(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		v := math.Float32frombits(binary.BigEndian.Uint32(bs))
 		return v, offset, nil
 
 	case d.isPositiveFixNum(code), code == def.Uint8, code == def.Uint16, code == def.Uint32, code == def.Uint64:
 		v, offset, err := d.AsUint(offset)
 		if err != nil {
-			break
+			return 0, 0, err
 		}
 		return float32(v), offset, nil
 
 	case d.isNegativeFixNum(code), code == def.Int8, code == def.Int16, code == def.Int32, code == def.Int64:
 		v, offset, err := d.AsInt(offset)
 		if err != nil {
-			break
+			return 0, 0, err
 		}
 		return float32(v), offset, nil
 
@@ -34,36 +39,43 @@ func (d *Decoder) AsFloat32(offset int) (float32, int, error) {
 		offset++
 		return 0, offset, nil
 	}
-	return 0, 0, d.errorTemplate(code, "AsFloat32")
+	return 0, 0, d.errorTemplate(code, offset, "float32")
 }
 
 func (d *Decoder) AsFloat64(offset int) (float64, int, error) {
-	code := d.data[offset]
+	code, _, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	switch {
 	case code == def.Float64:
-		offset++
-		bs, offset := d.readSize8(offset)
+		bs, offset, err := d.readSize8(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		v := math.Float64frombits(binary.BigEndian.Uint64(bs))
 		return v, offset, nil
 
 	case code == def.Float32:
-		offset++
-		bs, offset := d.readSize4(offset)
+		bs, offset, err := d.readSize4(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		v := math.Float32frombits(binary.BigEndian.Uint32(bs))
 		return float64(v), offset, nil
 
 	case d.isPositiveFixNum(code), code == def.Uint8, code == def.Uint16, code == def.Uint32, code == def.Uint64:
 		v, offset, err := d.AsUint(offset)
 		if err != nil {
-			break
+			return 0, 0, err
 		}
 		return float64(v), offset, nil
 
 	case d.isNegativeFixNum(code), code == def.Int8, code == def.Int16, code == def.Int32, code == def.Int64:
 		v, offset, err := d.AsInt(offset)
 		if err != nil {
-			break
+			return 0, 0, err
 		}
 		return float64(v), offset, nil
 
@@ -71,5 +83,5 @@ func (d *Decoder) AsFloat64(offset int) (float64, int, error) {
 		offset++
 		return 0, offset, nil
 	}
-	return 0, 0, d.errorTemplate(code, "AsFloat64")
+	return 0, 0, d.errorTemplate(code, offset, "float64")
 }
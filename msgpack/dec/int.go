@@ -32,59 +32,76 @@ func (d *Decoder) AsInt64(offset int) (int64, int, error) {
 
 func (d *Decoder) asInt(offset int) (int64, int, error) {
 
-	code := d.data[offset]
+	code, _, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	switch {
 	case d.isPositiveFixNum(code):
-		b, offset := d.readSize1(offset)
-		return int64(b), offset, nil
+		return int64(code), offset + 1, nil
 
 	case d.isNegativeFixNum(code):
-		b, offset := d.readSize1(offset)
-		return int64(int8(b)), offset, nil
+		return int64(int8(code)), offset + 1, nil
 
 	case code == def.Uint8:
-		offset++
-		b, offset := d.readSize1(offset)
+		b, offset, err := d.readSize1(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int64(uint8(b)), offset, nil
 
 	case code == def.Int8:
-		offset++
-		b, offset := d.readSize1(offset)
+		b, offset, err := d.readSize1(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int64(int8(b)), offset, nil
 
 	case code == def.Uint16:
-		offset++
-		bs, offset := d.readSize2(offset)
+		bs, offset, err := d.readSize2(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		v := binary.BigEndian.Uint16(bs)
 		return int64(v), offset, nil
 
 	case code == def.Int16:
-		offset++
-		bs, offset := d.readSize2(offset)
+		bs, offset, err := d.readSize2(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		v := int16(binary.BigEndian.Uint16(bs))
 		return int64(v), offset, nil
 
 	case code == def.Uint32:
-		offset++
-		bs, offset := d.readSize4(offset)
+		bs, offset, err := d.readSize4(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		v := binary.BigEndian.Uint32(bs)
 		return int64(v), offset, nil
 
 	case code == def.Int32:
-		offset++
-		bs, offset := d.readSize4(offset)
+		bs, offset, err := d.readSize4(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		v := int32(binary.BigEndian.Uint32(bs))
 		return int64(v), offset, nil
 
 	case code == def.Uint64:
-		offset++
-		bs, offset := d.readSize8(offset)
+		bs, offset, err := d.readSize8(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int64(binary.BigEndian.Uint64(bs)), offset, nil
 
 	case code == def.Int64:
-		offset++
-		bs, offset := d.readSize8(offset)
+		bs, offset, err := d.readSize8(offset + 1)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int64(binary.BigEndian.Uint64(bs)), offset, nil
 
 	case code == def.Nil:
@@ -92,7 +109,7 @@ func (d *Decoder) asInt(offset int) (int64, int, error) {
 		return 0, offset, nil
 	}
 
-	return 0, 0, d.errorTemplate(code, "asInt")
+	return 0, 0, d.errorTemplate(code, offset, "int")
 }
 
 func (d *Decoder) isPositiveFixNum(v byte) bool {
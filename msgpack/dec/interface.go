@@ -1,11 +1,16 @@
 package dec
 
 import (
+	"reflect"
+
 	"github.com/shamaton/msgpack/def"
 )
 
 func (d *Decoder) AsInterface(offset int) (interface{}, int, error) {
-	code := d.data[offset]
+	code, _, err := d.readSize1(offset)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	switch {
 	case code == def.Nil:
@@ -89,20 +94,62 @@ func (d *Decoder) AsInterface(offset int) (interface{}, int, error) {
 		}
 		return v, offset, err
 
-	//case code == def.Bin8, code == def.Bin16, code == def.Bin32:
-	//	v, offset, err := d.AsBin(offset)
-	//	if err != nil {
-	//		return nil, 0, err
-	//	}
-	//	return v, offset, err
+	case code == def.Bin8, code == def.Bin16, code == def.Bin32:
+		l, o, err := d.SliceLength(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		bs, o, err := d.readSizeN(o, l)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, err := MakeSlice[byte](d, o, l)
+		if err != nil {
+			return nil, 0, err
+		}
+		copy(v, bs)
+		return v, o, nil
+
+	case d.isExtType(offset, def.TimeStamp):
+		v, offset, err := d.AsDateTime(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		return v, offset, nil
+
+	case code == def.Fixext8 && d.isExtType(offset, d.complexTypeCode):
+		v, offset, err := d.AsComplex64(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		return v, offset, nil
+	case code == def.Fixext16 && d.isExtType(offset, d.complexTypeCode):
+		v, offset, err := d.AsComplex128(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		return v, offset, nil
+
+	case d.isExt(code):
+		v, offset, err := d.asExtInterface(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		return v, offset, nil
 
 	case d.isFixSlice(code), code == def.Array16, code == def.Array32:
 		l, o, err := d.SliceLength(offset)
 		if err != nil {
 			return nil, 0, err
 		}
+		if err = d.Enter(offset); err != nil {
+			return nil, 0, err
+		}
 
-		v := make([]interface{}, l)
+		v, err := MakeSlice[interface{}](d, o, l)
+		if err != nil {
+			return nil, 0, err
+		}
 		for i := 0; i < l; i++ {
 			vv, o2, err := d.AsInterface(o)
 			if err != nil {
@@ -111,6 +158,7 @@ func (d *Decoder) AsInterface(offset int) (interface{}, int, error) {
 			v[i] = vv
 			o = o2
 		}
+		d.Leave()
 		offset = o
 		return v, offset, nil
 
@@ -119,12 +167,23 @@ func (d *Decoder) AsInterface(offset int) (interface{}, int, error) {
 		if err != nil {
 			return nil, 0, err
 		}
-		v := make(map[interface{}]interface{}, l)
+		if err = d.Enter(offset); err != nil {
+			return nil, 0, err
+		}
+
+		v, err := MakeMap[interface{}, interface{}](d, o, l)
+		if err != nil {
+			return nil, 0, err
+		}
 		for i := 0; i < l; i++ {
 			key, o2, err := d.AsInterface(o)
 			if err != nil {
 				return nil, 0, err
 			}
+			key, err = d.mapKey(key, o)
+			if err != nil {
+				return nil, 0, err
+			}
 			value, o2, err := d.AsInterface(o2)
 			if err != nil {
 				return nil, 0, err
@@ -132,23 +191,38 @@ func (d *Decoder) AsInterface(offset int) (interface{}, int, error) {
 			v[key] = value
 			o = o2
 		}
+		d.Leave()
 		offset = o
 		return v, offset, nil
 	}
 
-	// ext
-	/*
-		for i := range extCoders {
-			if extCoders[i].IsType(offset, &d.data) {
-				v, offset, err := extCoders[i].AsValue(offset, k, &d.data)
-				if err != nil {
-					return nil, 0, err
-				}
-				return v, offset, nil
-			}
-		}
+	return nil, 0, d.errorTemplate(code, offset, "value")
+}
 
-	*/
+// mapKey returns key decoded at offset to be a key of map[interface{}]interface{}.
+// bin is converted to string, and keys which can not be compared, e.g. arrays and maps, return an error.
+func (d *Decoder) mapKey(key interface{}, offset int) (interface{}, error) {
+	if b, ok := key.([]byte); ok {
+		return string(b), nil
+	}
+	if key != nil && !reflect.ValueOf(key).Comparable() {
+		return nil, d.errorTemplate(d.data[offset], offset, "map key")
+	}
+	return key, nil
+}
 
-	return nil, 0, d.errorTemplate(code, "AsInterface")
+// isExtType checks the type of ext format at offset.
+func (d *Decoder) isExtType(offset int, extType int8) bool {
+	code := d.data[offset]
+	switch code {
+	case def.Fixext1, def.Fixext2, def.Fixext4, def.Fixext8, def.Fixext16:
+		return offset+1 < len(d.data) && int8(d.data[offset+1]) == extType
+	case def.Ext8:
+		return offset+2 < len(d.data) && int8(d.data[offset+2]) == extType
+	case def.Ext16:
+		return offset+3 < len(d.data) && int8(d.data[offset+3]) == extType
+	case def.Ext32:
+		return offset+5 < len(d.data) && int8(d.data[offset+5]) == extType
+	}
+	return false
 }
@@ -0,0 +1,132 @@
+package dec
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Limits restricts resources used by a Decoder, so that untrusted data can not exhaust memory or stack.
+// zero means no limit.
+type Limits struct {
+	// elements of an array, a slice or a map
+	MaxCollectionLength int
+	// bytes allocated for slices and maps in one decoding. sizes of map entries are estimated.
+	MaxAllocation int
+	// nesting of structs, and of arrays and maps in interface{} values or skipped values
+	MaxDepth int
+	// bytes of a string, a bin or the data of an ext
+	MaxStringLength int
+}
+
+// DefaultMaxDepth is the depth limit of decoders by default, which keeps recursive types and data from overflowing the stack.
+const DefaultMaxDepth = 10000
+
+var defaultLimits = Limits{MaxDepth: DefaultMaxDepth}
+
+// SetDefaultLimits sets the limits of decoders created after the call.
+func SetDefaultLimits(l Limits) {
+	defaultLimits = l
+}
+
+// DefaultLimits returns the limits of decoders created by NewDecoder.
+func DefaultLimits() Limits {
+	return defaultLimits
+}
+
+// SetLimits sets the limits of d.
+func (d *Decoder) SetLimits(l Limits) {
+	d.limits = l
+}
+
+// Limits returns the limits of d.
+func (d *Decoder) Limits() Limits {
+	return d.limits
+}
+
+// LimitError is returned when data exceeds a limit of Decoder.
+type LimitError struct {
+	// offset where the limit is exceeded
+	Offset int
+	// name of the field of Limits
+	Limit string
+	// value required by data
+	Value int
+	// value of the limit
+	Max int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("msgpackgen : %d exceeds %s %d at offset %d", e.Value, e.Limit, e.Max, e.Offset)
+}
+
+func (d *Decoder) limitError(offset int, limit string, value, max int) error {
+	return &LimitError{Offset: offset, Limit: limit, Value: value, Max: max}
+}
+
+// checkCollectionLength checks l elements of array or map, each of which is encoded with n bytes at least.
+func (d *Decoder) checkCollectionLength(offset, l, n int) error {
+	if max := d.limits.MaxCollectionLength; max > 0 && l > max {
+		return d.limitError(offset, "MaxCollectionLength", l, max)
+	}
+	// broken length is found before allocating memory
+	if l > (len(d.data)-offset)/n {
+		return d.shortBufferError(offset, l*n)
+	}
+	return nil
+}
+
+func (d *Decoder) checkStringLength(offset, l int) error {
+	if max := d.limits.MaxStringLength; max > 0 && l > max {
+		return d.limitError(offset, "MaxStringLength", l, max)
+	}
+	if l > len(d.data)-offset {
+		return d.shortBufferError(offset, l)
+	}
+	return nil
+}
+
+// allocate counts n elements of size bytes.
+func (d *Decoder) allocate(offset, n int, size uintptr) error {
+	max := d.limits.MaxAllocation
+	if max <= 0 {
+		return nil
+	}
+	if size > 0 && n > (max-d.allocated)/int(size) {
+		return d.limitError(offset, "MaxAllocation", d.allocated+n*int(size), max)
+	}
+	d.allocated += n * int(size)
+	return nil
+}
+
+// Enter is called before decoding a nested value at offset, and Leave after it.
+func (d *Decoder) Enter(offset int) error {
+	d.depth++
+	if max := d.limits.MaxDepth; max > 0 && d.depth > max {
+		return d.limitError(offset, "MaxDepth", d.depth, max)
+	}
+	return nil
+}
+
+// Leave is called after decoding a nested value.
+func (d *Decoder) Leave() {
+	d.depth--
+}
+
+// MakeSlice makes a slice of l elements in the allocation limit of d.
+func MakeSlice[T any](d *Decoder, offset, l int) ([]T, error) {
+	var v T
+	if err := d.allocate(offset, l, unsafe.Sizeof(v)); err != nil {
+		return nil, err
+	}
+	return make([]T, l), nil
+}
+
+// MakeMap makes a map of l entries in the allocation limit of d.
+func MakeMap[K comparable, V any](d *Decoder, offset, l int) (map[K]V, error) {
+	var k K
+	var v V
+	if err := d.allocate(offset, l, unsafe.Sizeof(k)+unsafe.Sizeof(v)); err != nil {
+		return nil, err
+	}
+	return make(map[K]V, l), nil
+}
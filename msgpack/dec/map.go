@@ -10,18 +10,41 @@ func (d *Decoder) isFixMap(v byte) bool {
 	return def.FixMap <= v && v <= def.FixMap+0x0f
 }
 
+// MapLength returns the number of entries of map.
+// the length is checked against the limits and the rest of data.
 func (d *Decoder) MapLength(offset int) (int, int, error) {
-	code, offset := d.readSize1(offset)
+	code, o, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	l, o, err := d.mapLength(code, o)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// a key and a value are encoded with 2 bytes at least
+	if err = d.checkCollectionLength(o, l, 2); err != nil {
+		return 0, 0, err
+	}
+	return l, o, nil
+}
 
+func (d *Decoder) mapLength(code byte, offset int) (int, int, error) {
 	switch {
 	case d.isFixMap(code):
 		return int(code - def.FixMap), offset, nil
 	case code == def.Map16:
-		bs, offset := d.readSize2(offset)
+		bs, offset, err := d.readSize2(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(binary.BigEndian.Uint16(bs)), offset, nil
 	case code == def.Map32:
-		bs, offset := d.readSize4(offset)
+		bs, offset, err := d.readSize4(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(binary.BigEndian.Uint32(bs)), offset, nil
 	}
-	return 0, 0, d.errorTemplate(code, "MapLength")
+	return 0, 0, d.errorTemplate(code, offset-def.Byte1, "map")
 }
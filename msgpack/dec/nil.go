@@ -2,6 +2,7 @@ package dec
 
 import "github.com/shamaton/msgpack/def"
 
+// IsCodeNil returns false at the end of data, so that the following read returns the error.
 func (d *Decoder) IsCodeNil(offset int) bool {
-	return def.Nil == d.data[offset]
+	return offset < len(d.data) && def.Nil == d.data[offset]
 }
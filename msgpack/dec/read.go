@@ -4,26 +4,43 @@ import (
 	"github.com/shamaton/msgpack/def"
 )
 
-func (d *Decoder) readSize1(index int) (byte, int) {
+// reads are checked against the length of data, so that broken data returns ShortBufferError instead of panic.
+// they are checked after Validate as well, because decoders may read the value in another structure.
+
+// Validate checks that the value at the start of data is complete within the limits of d, without decoding it.
+// data of d is cut to the value, and it returns the length of the value.
+func (d *Decoder) Validate() (int, error) {
+	n, err := d.JumpOffset(0)
+	if err != nil {
+		return 0, err
+	}
+	d.data = d.data[:n:n]
+	return n, nil
+}
+
+func (d *Decoder) readSize1(index int) (byte, int, error) {
 	rb := def.Byte1
-	return d.data[index], index + rb
+	if index >= len(d.data) {
+		return 0, 0, d.shortBufferError(index, rb)
+	}
+	return d.data[index], index + rb, nil
 }
 
-func (d *Decoder) readSize2(index int) ([]byte, int) {
-	rb := def.Byte2
-	return d.data[index : index+rb], index + rb
+func (d *Decoder) readSize2(index int) ([]byte, int, error) {
+	return d.readSizeN(index, def.Byte2)
 }
 
-func (d *Decoder) readSize4(index int) ([]byte, int) {
-	rb := def.Byte4
-	return d.data[index : index+rb], index + rb
+func (d *Decoder) readSize4(index int) ([]byte, int, error) {
+	return d.readSizeN(index, def.Byte4)
 }
 
-func (d *Decoder) readSize8(index int) ([]byte, int) {
-	rb := def.Byte8
-	return d.data[index : index+rb], index + rb
+func (d *Decoder) readSize8(index int) ([]byte, int, error) {
+	return d.readSizeN(index, def.Byte8)
 }
 
-func (d *Decoder) readSizeN(index, n int) ([]byte, int) {
-	return d.data[index : index+n], index + n
+func (d *Decoder) readSizeN(index, n int) ([]byte, int, error) {
+	if n < 0 || n > len(d.data)-index {
+		return nil, 0, d.shortBufferError(index, n)
+	}
+	return d.data[index : index+n], index + n, nil
 }
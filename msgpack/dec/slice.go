@@ -10,28 +10,77 @@ func (d *Decoder) isFixSlice(v byte) bool {
 	return def.FixArray <= v && v <= def.FixArray+0x0f
 }
 
+// SliceLength returns the number of elements of array, or the number of bytes of bin.
+// the length is checked against the limits and the rest of data.
 func (d *Decoder) SliceLength(offset int) (int, int, error) {
-	code, offset := d.readSize1(offset)
+	code, o, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	l, o, err := d.sliceLength(code, o)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if code == def.Bin8 || code == def.Bin16 || code == def.Bin32 {
+		err = d.checkStringLength(o, l)
+	} else {
+		err = d.checkCollectionLength(o, l, 1)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return l, o, nil
+}
+
+// ArrayLength returns the number of elements of array like SliceLength, but bin is an error.
+// slices of other than byte are read with it, because the bytes of bin are not encoded values.
+func (d *Decoder) ArrayLength(offset int) (int, int, error) {
+	code, _, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	if code == def.Bin8 || code == def.Bin16 || code == def.Bin32 {
+		return 0, 0, d.errorTemplate(code, offset, "array")
+	}
+	return d.SliceLength(offset)
+}
 
+func (d *Decoder) sliceLength(code byte, offset int) (int, int, error) {
 	switch {
 	case d.isFixSlice(code):
 		return int(code - def.FixArray), offset, nil
 	case code == def.Array16:
-		bs, offset := d.readSize2(offset)
+		bs, offset, err := d.readSize2(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(binary.BigEndian.Uint16(bs)), offset, nil
 	case code == def.Array32:
-		bs, offset := d.readSize4(offset)
+		bs, offset, err := d.readSize4(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(binary.BigEndian.Uint32(bs)), offset, nil
 
 	case code == def.Bin8:
-		l, offset := d.readSize1(offset)
+		l, offset, err := d.readSize1(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(uint8(l)), offset, nil
 	case code == def.Bin16:
-		bs, offset := d.readSize2(offset)
+		bs, offset, err := d.readSize2(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(binary.BigEndian.Uint16(bs)), offset, nil
 	case code == def.Bin32:
-		bs, offset := d.readSize4(offset)
+		bs, offset, err := d.readSize4(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(binary.BigEndian.Uint32(bs)), offset, nil
 	}
-	return 0, 0, d.errorTemplate(code, "SliceLength")
+	return 0, 0, d.errorTemplate(code, offset-def.Byte1, "array or bin")
 }
@@ -14,26 +14,49 @@ func (d *Decoder) isFixString(v byte) bool {
 	return def.FixStr <= v && v <= def.FixStr+0x1f
 }
 
+// StringByteLength returns the number of bytes of string.
+// the length is checked against the limits and the rest of data.
 func (d *Decoder) StringByteLength(offset int) (int, int, error) {
-	code := d.data[offset]
-	offset++
+	code, o, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	l, o, err := d.stringByteLength(code, o)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err = d.checkStringLength(o, l); err != nil {
+		return 0, 0, err
+	}
+	return l, o, nil
+}
 
+func (d *Decoder) stringByteLength(code byte, offset int) (int, int, error) {
 	if d.isFixString(code) {
 		l := int(code - def.FixStr)
 		return l, offset, nil
 	} else if code == def.Str8 {
-		b, offset := d.readSize1(offset)
+		b, offset, err := d.readSize1(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(b), offset, nil
 	} else if code == def.Str16 {
-		b, offset := d.readSize2(offset)
+		b, offset, err := d.readSize2(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(binary.BigEndian.Uint16(b)), offset, nil
 	} else if code == def.Str32 {
-		b, offset := d.readSize4(offset)
+		b, offset, err := d.readSize4(offset)
+		if err != nil {
+			return 0, 0, err
+		}
 		return int(binary.BigEndian.Uint32(b)), offset, nil
 	} else if code == def.Nil {
 		return 0, offset, nil
 	}
-	return 0, 0, d.errorTemplate(code, "StringByteLength")
+	return 0, 0, d.errorTemplate(code, offset-def.Byte1, "string")
 }
 
 func (d *Decoder) AsString(offset int) (string, int, error) {
@@ -41,13 +64,16 @@ func (d *Decoder) AsString(offset int) (string, int, error) {
 	if err != nil {
 		return emptyString, 0, err
 	}
-	bs, offset := d.asStringByte(offset, l)
+	bs, offset, err := d.asStringByte(offset, l)
+	if err != nil {
+		return emptyString, 0, err
+	}
 	return *(*string)(unsafe.Pointer(&bs)), offset, nil
 }
 
-func (d *Decoder) asStringByte(offset int, l int) ([]byte, int) {
+func (d *Decoder) asStringByte(offset int, l int) ([]byte, int, error) {
 	if l < 1 {
-		return emptyBytes, offset
+		return emptyBytes, offset, nil
 	}
 
 	return d.readSizeN(offset, l)
@@ -8,41 +8,56 @@ import (
 )
 
 func (d *Decoder) CheckStructHeader(fieldNum, offset int) (int, error) {
-	code, offset := d.readSize1(offset)
-	var l int
+	l, o, err := d.ReadStructHeader(offset)
+	if err != nil {
+		return 0, err
+	}
+	if fieldNum != l {
+		return 0, &DecodeError{
+			Offset:   offset,
+			Expected: fmt.Sprintf("%d elements", fieldNum),
+			Code:     d.data[offset],
+			Err:      fmt.Errorf("data length wrong %d : %d", fieldNum, l),
+		}
+	}
+	return o, nil
+}
+
+// ReadStructHeader returns the number of elements of array or map encoded from struct.
+func (d *Decoder) ReadStructHeader(offset int) (int, int, error) {
+	code, offset, err := d.readSize1(offset)
+	if err != nil {
+		return 0, 0, err
+	}
+
 	switch {
 	case d.isFixSlice(code):
-		l = int(code - def.FixArray)
-
+		return int(code - def.FixArray), offset, nil
 	case code == def.Array16:
-		bs, o := d.readSize2(offset)
-		l = int(binary.BigEndian.Uint16(bs))
-		offset = o
+		return d.readLength(offset, def.Byte2)
 	case code == def.Array32:
-		bs, o := d.readSize4(offset)
-		l = int(binary.BigEndian.Uint32(bs))
-		offset = o
+		return d.readLength(offset, def.Byte4)
 
 	case d.isFixMap(code):
-		l = int(code - def.FixMap)
+		return int(code - def.FixMap), offset, nil
 	case code == def.Map16:
-		bs, o := d.readSize2(offset)
-		l = int(binary.BigEndian.Uint16(bs))
-		offset = o
+		return d.readLength(offset, def.Byte2)
 	case code == def.Map32:
-		bs, o := d.readSize4(offset)
-		l = int(binary.BigEndian.Uint32(bs))
-		offset = o
+		return d.readLength(offset, def.Byte4)
 	}
+	return 0, 0, d.errorTemplate(code, offset-def.Byte1, "struct")
+}
 
-	if fieldNum != l {
-		return 0, fmt.Errorf("data length wrong %d : %d", fieldNum, l)
+// JumpOffset returns the offset after the value at offset.
+func (d *Decoder) JumpOffset(offset int) (int, error) {
+	code, offset, err := d.readSize1(offset)
+	if err != nil {
+		return 0, err
 	}
-	return offset, nil
-}
 
-func (d *Decoder) JumpOffset(offset int) int {
-	code, offset := d.readSize1(offset)
+	// bytes after the code and the length, and the number of entries in array or map
+	var size, entries int
+	var isBytes, isMap bool
 	switch {
 	case code == def.True, code == def.False, code == def.Nil:
 		// do nothing
@@ -50,93 +65,98 @@ func (d *Decoder) JumpOffset(offset int) int {
 	case d.isPositiveFixNum(code) || d.isNegativeFixNum(code):
 		// do nothing
 	case code == def.Uint8, code == def.Int8:
-		offset += def.Byte1
+		size = def.Byte1
 	case code == def.Uint16, code == def.Int16:
-		offset += def.Byte2
+		size = def.Byte2
 	case code == def.Uint32, code == def.Int32, code == def.Float32:
-		offset += def.Byte4
+		size = def.Byte4
 	case code == def.Uint64, code == def.Int64, code == def.Float64:
-		offset += def.Byte8
+		size = def.Byte8
 
 	case d.isFixString(code):
-		offset += int(code - def.FixStr)
+		size, isBytes = int(code-def.FixStr), true
 	case code == def.Str8, code == def.Bin8:
-		b, o := d.readSize1(offset)
-		o += int(b)
-		offset = o
+		size, offset, err = d.readLength(offset, def.Byte1)
+		isBytes = true
 	case code == def.Str16, code == def.Bin16:
-		bs, o := d.readSize2(offset)
-		o += int(binary.BigEndian.Uint16(bs))
-		offset = o
+		size, offset, err = d.readLength(offset, def.Byte2)
+		isBytes = true
 	case code == def.Str32, code == def.Bin32:
-		bs, o := d.readSize4(offset)
-		o += int(binary.BigEndian.Uint32(bs))
-		offset = o
+		size, offset, err = d.readLength(offset, def.Byte4)
+		isBytes = true
 
 	case d.isFixSlice(code):
-		l := int(code - def.FixArray)
-		for i := 0; i < l; i++ {
-			offset = d.JumpOffset(offset)
-		}
+		entries = int(code - def.FixArray)
 	case code == def.Array16:
-		bs, o := d.readSize2(offset)
-		l := int(binary.BigEndian.Uint16(bs))
-		for i := 0; i < l; i++ {
-			o = d.JumpOffset(o)
-		}
-		offset = o
+		entries, offset, err = d.readLength(offset, def.Byte2)
 	case code == def.Array32:
-		bs, o := d.readSize4(offset)
-		l := int(binary.BigEndian.Uint32(bs))
-		for i := 0; i < l; i++ {
-			o = d.JumpOffset(o)
-		}
-		offset = o
+		entries, offset, err = d.readLength(offset, def.Byte4)
 
 	case d.isFixMap(code):
-		l := int(code - def.FixMap)
-		for i := 0; i < l*2; i++ {
-			offset = d.JumpOffset(offset)
-		}
+		entries, isMap = int(code-def.FixMap), true
 	case code == def.Map16:
-		bs, o := d.readSize2(offset)
-		l := int(binary.BigEndian.Uint16(bs))
-		for i := 0; i < l*2; i++ {
-			o = d.JumpOffset(o)
-		}
-		offset = o
+		entries, offset, err = d.readLength(offset, def.Byte2)
+		isMap = true
 	case code == def.Map32:
-		bs, o := d.readSize4(offset)
-		l := int(binary.BigEndian.Uint32(bs))
-		for i := 0; i < l*2; i++ {
-			o = d.JumpOffset(o)
+		entries, offset, err = d.readLength(offset, def.Byte4)
+		isMap = true
+
+	// timestamps, complex numbers and registered ext types are read in the same way
+	case d.isExt(code):
+		_, _, offset, err = d.readExt(offset - def.Byte1)
+
+	default:
+		return 0, d.errorTemplate(code, offset-def.Byte1, "value")
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// lengths are limited before the values are read, e.g. by Decoder of stream which reads data until them
+	values := entries
+	switch {
+	case isBytes:
+		err = d.checkStringLength(offset, size)
+	case isMap:
+		err = d.checkCollectionLength(offset, entries, 2)
+		values *= 2
+	case entries > 0:
+		err = d.checkCollectionLength(offset, entries, 1)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if _, offset, err = d.readSizeN(offset, size); err != nil {
+		return 0, err
+	}
+	if values == 0 {
+		return offset, nil
+	}
+
+	if err = d.Enter(offset); err != nil {
+		return 0, err
+	}
+	for i := 0; i < values; i++ {
+		if offset, err = d.JumpOffset(offset); err != nil {
+			return 0, err
 		}
-		offset = o
-
-	case code == def.Fixext1:
-		offset += def.Byte1 + def.Byte1
-	case code == def.Fixext2:
-		offset += def.Byte1 + def.Byte2
-	case code == def.Fixext4:
-		offset += def.Byte1 + def.Byte4
-	case code == def.Fixext8:
-		offset += def.Byte1 + def.Byte8
-	case code == def.Fixext16:
-		offset += def.Byte1 + def.Byte16
-
-	case code == def.Ext8:
-		b, o := d.readSize1(offset)
-		o += def.Byte1 + int(b)
-		offset = o
-	case code == def.Ext16:
-		bs, o := d.readSize2(offset)
-		o += def.Byte1 + int(binary.BigEndian.Uint16(bs))
-		offset = o
-	case code == def.Ext32:
-		bs, o := d.readSize4(offset)
-		o += def.Byte1 + int(binary.BigEndian.Uint32(bs))
-		offset = o
+	}
+	d.Leave()
+	return offset, nil
+}
 
+// readLength reads the big endian length of n bytes.
+func (d *Decoder) readLength(offset, n int) (int, int, error) {
+	bs, offset, err := d.readSizeN(offset, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	switch n {
+	case def.Byte1:
+		return int(bs[0]), offset, nil
+	case def.Byte2:
+		return int(binary.BigEndian.Uint16(bs)), offset, nil
 	}
-	return offset
+	return int(binary.BigEndian.Uint32(bs)), offset, nil
 }
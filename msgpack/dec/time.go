@@ -9,24 +9,41 @@ import (
 )
 
 func (d *Decoder) AsDateTime(offset int) (time.Time, int, error) {
-	code, offset := d.readSize1(offset)
+	t, offset, err := d.asDateTime(offset)
+	if err == nil && d.timeAsUTC {
+		t = t.UTC()
+	}
+	return t, offset, err
+}
+
+func (d *Decoder) asDateTime(offset int) (time.Time, int, error) {
+	code, offset, err := d.readSize1(offset)
+	if err != nil {
+		return time.Time{}, 0, err
+	}
 
 	switch code {
 	case def.Fixext4:
-		t, offset := d.readSize1(offset)
-		if int8(t) != def.TimeStamp {
+		// type and seconds
+		bs, offset, err := d.readSizeN(offset, def.Byte1+def.Byte4)
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+		if t := int8(bs[0]); t != def.TimeStamp {
 			return time.Time{}, 0, fmt.Errorf("fixext4. time type is diffrent %d, %d", t, def.TimeStamp)
 		}
-		bs, offset := d.readSize4(offset)
-		return time.Unix(int64(binary.BigEndian.Uint32(bs)), 0), offset, nil
+		return time.Unix(int64(binary.BigEndian.Uint32(bs[1:])), 0), offset, nil
 
 	case def.Fixext8:
-		t, offset := d.readSize1(offset)
-		if int8(t) != def.TimeStamp {
+		// type, nanoseconds and seconds
+		bs, offset, err := d.readSizeN(offset, def.Byte1+def.Byte8)
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+		if t := int8(bs[0]); t != def.TimeStamp {
 			return time.Time{}, 0, fmt.Errorf("fixext8. time type is diffrent %d, %d", t, def.TimeStamp)
 		}
-		bs, offset := d.readSize8(offset)
-		data64 := binary.BigEndian.Uint64(bs)
+		data64 := binary.BigEndian.Uint64(bs[1:])
 		nano := int64(data64 >> 34)
 		if nano > 999999999 {
 			return time.Time{}, 0, fmt.Errorf("in timestamp 64 formats, nanoseconds must not be larger than 999999999 : %d", nano)
@@ -34,23 +51,28 @@ func (d *Decoder) AsDateTime(offset int) (time.Time, int, error) {
 		return time.Unix(int64(data64&0x00000003ffffffff), nano), offset, nil
 
 	case def.Ext8:
-		c, offset := d.readSize1(offset)
-		if int8(c) != 12 {
-			return time.Time{}, 0, fmt.Errorf("ext8. time ext length is diffrent %d, %d", c, 12)
+		// length, type, nanoseconds and seconds. the length is checked first not to read over the ext.
+		c, offset, err := d.readSize1(offset)
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+		if c != 12 {
+			return time.Time{}, 0, fmt.Errorf("ext8. time ext length is diffrent %d, %d", int8(c), 12)
+		}
+		bs, offset, err := d.readSizeN(offset, def.Byte1+def.Byte4+def.Byte8)
+		if err != nil {
+			return time.Time{}, 0, err
 		}
-		t, offset := d.readSize1(offset)
-		if int8(t) != def.TimeStamp {
+		if t := int8(bs[0]); t != def.TimeStamp {
 			return time.Time{}, 0, fmt.Errorf("ext8. time type is diffrent %d, %d", t, def.TimeStamp)
 		}
-		nanobs, offset := d.readSize4(offset)
-		secbs, offset := d.readSize8(offset)
-		nano := binary.BigEndian.Uint32(nanobs)
+		nano := binary.BigEndian.Uint32(bs[1:5])
 		if nano > 999999999 {
 			return time.Time{}, 0, fmt.Errorf("in timestamp 96 formats, nanoseconds must not be larger than 999999999 : %d", nano)
 		}
-		sec := binary.BigEndian.Uint64(secbs)
+		sec := binary.BigEndian.Uint64(bs[5:])
 		return time.Unix(int64(sec), int64(nano)), offset, nil
 	}
 
-	return time.Time{}, 0, d.errorTemplate(code, "AsDateTime")
+	return time.Time{}, 0, d.errorTemplate(code, offset-def.Byte1, "time")
 }
@@ -1,12 +1,60 @@
 package enc
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/shamaton/msgpack/def"
 )
 
-const complexType = -128
+// DefaultComplexTypeCode is the ext type of complex64 and complex128 by default.
+const DefaultComplexTypeCode int8 = -128
+
+var complexTypeCode = DefaultComplexTypeCode
+
+// ext types of complex64 and complex128 used by resolvers, which SetExtEncoder can not set
+var usedComplexTypeCodes [256]bool
+
+// SetComplexTypeCode sets the ext type of complex64 and complex128 of encoders created after.
+// the type of timestamp and ext types set by SetExtEncoder can not be used.
+func SetComplexTypeCode(code int8) error {
+	if err := CheckComplexTypeCode(code); err != nil {
+		return err
+	}
+	complexTypeCode = code
+	return nil
+}
+
+// CheckComplexTypeCode returns an error when code can not be the ext type of complex64 and complex128.
+func CheckComplexTypeCode(code int8) error {
+	if code == def.TimeStamp {
+		return fmt.Errorf("msgpackgen : ext type %d is reserved for timestamp", code)
+	}
+	if x := extEncoders[uint8(code)]; x != nil {
+		return fmt.Errorf("msgpackgen : ext type %d is set to %v", code, x.typ)
+	}
+	return nil
+}
+
+// UseComplexTypeCode checks code with CheckComplexTypeCode, and keeps SetExtEncoder from setting it,
+// because code is the ext type of complex64 and complex128 of a resolver.
+func UseComplexTypeCode(code int8) error {
+	if err := CheckComplexTypeCode(code); err != nil {
+		return err
+	}
+	usedComplexTypeCodes[uint8(code)] = true
+	return nil
+}
+
+// ComplexTypeCode returns the ext type of complex64 and complex128.
+func ComplexTypeCode() int8 {
+	return complexTypeCode
+}
+
+// SetComplexTypeCode sets the ext type of complex64 and complex128 written by e, which is checked by CheckComplexTypeCode.
+func (e *Encoder) SetComplexTypeCode(code int8) {
+	e.complexTypeCode = code
+}
 
 func (e *Encoder) CalcComplex64(v complex64) int {
 	return def.Byte1 + def.Byte1 + def.Byte8
@@ -18,7 +66,7 @@ func (e *Encoder) CalcComplex128(v complex128) int {
 
 func (e *Encoder) WriteComplex64(v complex64, offset int) int {
 	offset = e.setByte1Int(def.Fixext8, offset)
-	offset = e.setByte1Int(complexType, offset)
+	offset = e.setByte1Int(int(e.complexTypeCode), offset)
 	offset = e.setByte4Uint64(uint64(math.Float32bits(real(v))), offset)
 	offset = e.setByte4Uint64(uint64(math.Float32bits(imag(v))), offset)
 	return offset
@@ -26,7 +74,7 @@ func (e *Encoder) WriteComplex64(v complex64, offset int) int {
 
 func (e *Encoder) WriteComplex128(v complex128, offset int) int {
 	offset = e.setByte1Int(def.Fixext16, offset)
-	offset = e.setByte1Int(complexType, offset)
+	offset = e.setByte1Int(int(e.complexTypeCode), offset)
 	offset = e.setByte8Uint64(math.Float64bits(real(v)), offset)
 	offset = e.setByte8Uint64(math.Float64bits(imag(v)), offset)
 	return offset
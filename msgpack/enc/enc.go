@@ -1,27 +1,50 @@
 package enc
 
 type Encoder struct {
-	//buf  *bytes.Buffer
-	//size int
 	d []byte
+	// d is got from the pool
+	pooled bool
+	// encodes structs in interface{} values with the options of the call, instead of the package encoder
+	structEncoder StructEncoder
+	// ext type of complex64 and complex128
+	complexTypeCode int8
+	// structs and keys of maps in interface{} values, kept by CalcInterface for WriteInterface
+	kept map[keptKey][]interface{}
 }
 
 func NewEncoder() *Encoder {
-	return &Encoder{}
+	return &Encoder{complexTypeCode: complexTypeCode}
+}
+
+// Reset drops the bytes and the settings of e, so that e can be reused.
+func (e *Encoder) Reset() {
+	*e = Encoder{complexTypeCode: complexTypeCode}
 }
 
 func (e *Encoder) MakeBytes(size int) {
-	//e.size = size
-	//e.buf = bufPool.Get(size)
-	//e.d = e.buf.Bytes()
-	e.d = make([]byte, size)
+	e.d, e.pooled = getBytes(size)
+}
+
+// AppendBytes makes size bytes after dst to be encoded, in the spare capacity of dst if possible.
+// otherwise dst is copied to a buffer from the pool.
+func (e *Encoder) AppendBytes(dst []byte, size int) {
+	if cap(dst)-len(dst) >= size {
+		e.d, e.pooled = dst[:len(dst)+size], false
+		return
+	}
+	e.d, e.pooled = getBytes(len(dst) + size)
+	copy(e.d, dst)
 }
 
 func (e *Encoder) EncodedBytes() []byte {
-	//return e.d[:e.size]
 	return e.d
 }
 
+// ReleaseBytes returns the bytes to the pool when encoding failed.
+// the spare capacity of the caller is not released.
 func (e *Encoder) ReleaseBytes() {
-	// bufPool.Put(e.buf)
+	if e.pooled {
+		PutBytes(e.d)
+	}
+	e.d, e.pooled = nil, false
 }
@@ -0,0 +1,182 @@
+package enc
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/shamaton/msgpack/def"
+)
+
+// ExtEncoder writes values of T as the data of an ext type.
+type ExtEncoder[T any] interface {
+	// ExtSize returns the length of the data of v.
+	ExtSize(v T) (int, error)
+	// WriteExt writes the data of v to b, whose length is returned by ExtSize.
+	WriteExt(v T, b []byte) error
+}
+
+type extEncoder struct {
+	typ reflect.Type
+	// ExtEncoder[T]
+	coder interface{}
+	// for values in interface{}
+	size  func(v interface{}) (int, error)
+	write func(v interface{}, b []byte) error
+}
+
+// ext encoders are set before encoding, by the index of uint8(code)
+var (
+	extEncoders [256]*extEncoder
+	extCodes    = map[reflect.Type]int8{}
+)
+
+// SetExtEncoder sets x to encode values of T as the ext type code.
+// a type can not be set to two ext types, nor an ext type to two types.
+func SetExtEncoder[T any](code int8, x ExtEncoder[T]) error {
+	if code == complexTypeCode || usedComplexTypeCodes[uint8(code)] {
+		return fmt.Errorf("msgpackgen : ext type %d is used for complex numbers", code)
+	}
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	if old := extEncoders[uint8(code)]; old != nil && old.typ != typ {
+		return fmt.Errorf("msgpackgen : ext type %d is set to both %v and %v", code, old.typ, typ)
+	}
+	if old, found := extCodes[typ]; found && old != code {
+		return fmt.Errorf("msgpackgen : %v is set to both ext type %d and %d", typ, old, code)
+	}
+
+	extEncoders[uint8(code)] = &extEncoder{
+		typ:   typ,
+		coder: x,
+		size:  func(v interface{}) (int, error) { return x.ExtSize(v.(T)) },
+		write: func(v interface{}, b []byte) error { return x.WriteExt(v.(T), b) },
+	}
+	extCodes[typ] = code
+	return nil
+}
+
+func extEncoderOf[T any](code int8, v T) (ExtEncoder[T], error) {
+	if x := extEncoders[uint8(code)]; x != nil {
+		if coder, ok := x.coder.(ExtEncoder[T]); ok {
+			return coder, nil
+		}
+	}
+	return nil, fmt.Errorf("msgpackgen : ext type %d is not set for %T", code, v)
+}
+
+// CalcExt returns the size of v encoded as the ext type code.
+func CalcExt[T any](e *Encoder, code int8, v T) (int, error) {
+	x, err := extEncoderOf(code, v)
+	if err != nil {
+		return 0, err
+	}
+	l, err := x.ExtSize(v)
+	if err != nil {
+		return 0, err
+	}
+	size, err := e.calcExtHeader(l)
+	if err != nil {
+		return 0, err
+	}
+	return size + l, nil
+}
+
+// WriteExt writes v as the ext type code, whose size has been calculated by CalcExt.
+func WriteExt[T any](e *Encoder, code int8, v T, offset int) (int, error) {
+	x, err := extEncoderOf(code, v)
+	if err != nil {
+		return 0, err
+	}
+	l, err := x.ExtSize(v)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = e.calcExtHeader(l); err != nil {
+		return 0, err
+	}
+	offset = e.writeExtHeader(code, l, offset)
+	if err = x.WriteExt(v, e.d[offset:offset+l]); err != nil {
+		return 0, err
+	}
+	return offset + l, nil
+}
+
+// calcExtValue returns the size of rv, if its type is set to an ext type.
+func (e *Encoder) calcExtValue(rv reflect.Value) (int, bool, error) {
+	code, found := extCodes[rv.Type()]
+	if !found {
+		return 0, false, nil
+	}
+	l, err := extEncoders[uint8(code)].size(rv.Interface())
+	if err != nil {
+		return 0, true, err
+	}
+	size, err := e.calcExtHeader(l)
+	if err != nil {
+		return 0, true, err
+	}
+	return size + l, true, nil
+}
+
+// writeExtValue writes rv, if its type is set to an ext type.
+func (e *Encoder) writeExtValue(rv reflect.Value, offset int) (int, bool, error) {
+	code, found := extCodes[rv.Type()]
+	if !found {
+		return 0, false, nil
+	}
+	x := extEncoders[uint8(code)]
+	v := rv.Interface()
+	l, err := x.size(v)
+	if err != nil {
+		return 0, true, err
+	}
+	if _, err = e.calcExtHeader(l); err != nil {
+		return 0, true, err
+	}
+	offset = e.writeExtHeader(code, l, offset)
+	if err = x.write(v, e.d[offset:offset+l]); err != nil {
+		return 0, true, err
+	}
+	return offset + l, true, nil
+}
+
+func (e *Encoder) calcExtHeader(l int) (int, error) {
+	switch {
+	case l < 0:
+		return 0, fmt.Errorf("invalid ext length : %d", l)
+	case l == 1, l == 2, l == 4, l == 8, l == 16:
+		return def.Byte1 + def.Byte1, nil
+	case l <= math.MaxUint8:
+		return def.Byte1 + def.Byte1 + def.Byte1, nil
+	case l <= math.MaxUint16:
+		return def.Byte1 + def.Byte2 + def.Byte1, nil
+	case uint(l) <= math.MaxUint32:
+		return def.Byte1 + def.Byte4 + def.Byte1, nil
+	}
+	return 0, fmt.Errorf("not support this ext length : %d", l)
+}
+
+func (e *Encoder) writeExtHeader(code int8, l, offset int) int {
+	switch {
+	case l == 1:
+		offset = e.setByte1Int(def.Fixext1, offset)
+	case l == 2:
+		offset = e.setByte1Int(def.Fixext2, offset)
+	case l == 4:
+		offset = e.setByte1Int(def.Fixext4, offset)
+	case l == 8:
+		offset = e.setByte1Int(def.Fixext8, offset)
+	case l == 16:
+		offset = e.setByte1Int(def.Fixext16, offset)
+	case l <= math.MaxUint8:
+		offset = e.setByte1Int(def.Ext8, offset)
+		offset = e.setByte1Int(l, offset)
+	case l <= math.MaxUint16:
+		offset = e.setByte1Int(def.Ext16, offset)
+		offset = e.setByte2Int(l, offset)
+	default:
+		offset = e.setByte1Int(def.Ext32, offset)
+		offset = e.setByte4Int(l, offset)
+	}
+	return e.setByte1Int(int(code), offset)
+}
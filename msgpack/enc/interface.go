@@ -0,0 +1,434 @@
+package enc
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// StructEncoder encodes values which Encoder can not encode by itself, e.g. structs.
+type StructEncoder func(v interface{}, asArray bool) ([]byte, error)
+
+var structEncoder StructEncoder = func(v interface{}, asArray bool) ([]byte, error) {
+	return nil, fmt.Errorf("msgpackgen : struct encoder is not set. can not encode %T", v)
+}
+
+// SetStructEncoder sets the encoder used by CalcInterface, unless the encoder has its own.
+func SetStructEncoder(f StructEncoder) {
+	structEncoder = f
+}
+
+// SetStructEncoder sets the encoder used by CalcInterface of e, which holds the options of the call.
+func (e *Encoder) SetStructEncoder(f StructEncoder) {
+	e.structEncoder = f
+}
+
+func (e *Encoder) encodeStruct(v interface{}, asArray bool) ([]byte, error) {
+	if e.structEncoder != nil {
+		return e.structEncoder(v, asArray)
+	}
+	return structEncoder(v, asArray)
+}
+
+var typeTime = reflect.TypeOf(time.Time{})
+
+// kept is what CalcInterface keeps for WriteInterface, in the order of the traversal of a value:
+// encoded structs, so that they are encoded once, and keys of maps, so that maps are written in the same order.
+type kept struct {
+	values []interface{}
+	next   int
+}
+
+func (k *kept) add(v interface{}) {
+	k.values = append(k.values, v)
+}
+
+// take returns the next value kept, or nil when WriteInterface is called without CalcInterface.
+func (k *kept) take() interface{} {
+	if k == nil || k.next >= len(k.values) {
+		return nil
+	}
+	v := k.values[k.next]
+	k.next++
+	return v
+}
+
+// keptKey identifies a value by the words of the interface, which are the same in CalcInterface and WriteInterface.
+type keptKey [2]unsafe.Pointer
+
+func keyOf(v interface{}) keptKey {
+	return *(*keptKey)(unsafe.Pointer(&v))
+}
+
+// CalcInterface returns the size of v, whose type is resolved at runtime.
+// structs and keys of maps in v are kept for WriteInterface.
+func (e *Encoder) CalcInterface(v interface{}, asArray bool) (int, error) {
+	k := &kept{}
+	size, err := e.calcInterface(v, asArray, k)
+	if err != nil || len(k.values) == 0 {
+		return size, err
+	}
+	if e.kept == nil {
+		e.kept = map[keptKey][]interface{}{}
+	}
+	e.kept[keyOf(v)] = k.values
+	return size, nil
+}
+
+func (e *Encoder) calcInterface(v interface{}, asArray bool, k *kept) (int, error) {
+	switch vv := v.(type) {
+	case nil:
+		return e.CalcNil(), nil
+	case bool:
+		return e.CalcBool(vv), nil
+	case int:
+		return e.CalcInt(vv), nil
+	case int8:
+		return e.CalcInt8(vv), nil
+	case int16:
+		return e.CalcInt16(vv), nil
+	case int32:
+		return e.CalcInt32(vv), nil
+	case int64:
+		return e.CalcInt64(vv), nil
+	case uint:
+		return e.CalcUint(vv), nil
+	case uint8:
+		return e.CalcUint8(vv), nil
+	case uint16:
+		return e.CalcUint16(vv), nil
+	case uint32:
+		return e.CalcUint32(vv), nil
+	case uint64:
+		return e.CalcUint64(vv), nil
+	case float32:
+		return e.CalcFloat32(vv), nil
+	case float64:
+		return e.CalcFloat64(vv), nil
+	case string:
+		return e.CalcString(vv), nil
+	case []byte:
+		if vv == nil {
+			return e.CalcNil(), nil
+		}
+		size, err := e.CalcSliceLength(len(vv), true)
+		if err != nil {
+			return 0, err
+		}
+		return size + len(vv), nil
+	case complex64:
+		return e.CalcComplex64(vv), nil
+	case complex128:
+		return e.CalcComplex128(vv), nil
+	case time.Time:
+		return e.CalcTime(vv), nil
+
+	case []interface{}:
+		if vv == nil {
+			return e.CalcNil(), nil
+		}
+		size, err := e.CalcSliceLength(len(vv), false)
+		if err != nil {
+			return 0, err
+		}
+		for _, elm := range vv {
+			s, err := e.calcInterface(elm, asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			size += s
+		}
+		return size, nil
+
+	case map[string]interface{}:
+		if vv == nil {
+			return e.CalcNil(), nil
+		}
+		size, err := e.CalcMapLength(len(vv))
+		if err != nil {
+			return 0, err
+		}
+		keys := make([]string, 0, len(vv))
+		for key := range vv {
+			keys = append(keys, key)
+		}
+		k.add(keys)
+		for _, key := range keys {
+			size += e.CalcString(key)
+			s, err := e.calcInterface(vv[key], asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			size += s
+		}
+		return size, nil
+	}
+
+	return e.calcValue(reflect.ValueOf(v), asArray, k)
+}
+
+func (e *Encoder) calcValue(rv reflect.Value, asArray bool, k *kept) (int, error) {
+	if size, ok, err := e.calcExtValue(rv); ok {
+		return size, err
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return e.CalcBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.calcInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.calcUint(rv.Uint()), nil
+	case reflect.Float32:
+		return e.CalcFloat32(float32(rv.Float())), nil
+	case reflect.Float64:
+		return e.CalcFloat64(rv.Float()), nil
+	case reflect.String:
+		return e.CalcString(rv.String()), nil
+	case reflect.Complex64:
+		return e.CalcComplex64(complex64(rv.Complex())), nil
+	case reflect.Complex128:
+		return e.CalcComplex128(rv.Complex()), nil
+
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return e.CalcNil(), nil
+		}
+		return e.calcValue(rv.Elem(), asArray, k)
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return e.CalcNil(), nil
+		}
+		isChildTypeByte := rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8
+		size, err := e.CalcSliceLength(rv.Len(), isChildTypeByte)
+		if err != nil {
+			return 0, err
+		}
+		if isChildTypeByte {
+			return size + rv.Len(), nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			s, err := e.calcValue(rv.Index(i), asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			size += s
+		}
+		return size, nil
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return e.CalcNil(), nil
+		}
+		size, err := e.CalcMapLength(rv.Len())
+		if err != nil {
+			return 0, err
+		}
+		keys := rv.MapKeys()
+		k.add(keys)
+		for _, key := range keys {
+			s, err := e.calcValue(key, asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			size += s
+			s, err = e.calcValue(rv.MapIndex(key), asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			size += s
+		}
+		return size, nil
+	}
+
+	if rv.Type() == typeTime {
+		return e.CalcTime(rv.Interface().(time.Time)), nil
+	}
+
+	b, err := e.encodeStruct(rv.Interface(), asArray)
+	if err != nil {
+		return 0, err
+	}
+	k.add(b)
+	return len(b), nil
+}
+
+// WriteInterface writes v, whose size has been calculated by CalcInterface.
+// structs and maps in v are written as kept by CalcInterface.
+func (e *Encoder) WriteInterface(v interface{}, offset int, asArray bool) (int, error) {
+	var k *kept
+	if values, ok := e.kept[keyOf(v)]; ok {
+		k = &kept{values: values}
+	}
+	return e.writeInterface(v, offset, asArray, k)
+}
+
+func (e *Encoder) writeInterface(v interface{}, offset int, asArray bool, k *kept) (int, error) {
+	switch vv := v.(type) {
+	case nil:
+		return e.WriteNil(offset), nil
+	case bool:
+		return e.WriteBool(vv, offset), nil
+	case int:
+		return e.WriteInt(vv, offset), nil
+	case int8:
+		return e.WriteInt8(vv, offset), nil
+	case int16:
+		return e.WriteInt16(vv, offset), nil
+	case int32:
+		return e.WriteInt32(vv, offset), nil
+	case int64:
+		return e.WriteInt64(vv, offset), nil
+	case uint:
+		return e.WriteUint(vv, offset), nil
+	case uint8:
+		return e.WriteUint8(vv, offset), nil
+	case uint16:
+		return e.WriteUint16(vv, offset), nil
+	case uint32:
+		return e.WriteUint32(vv, offset), nil
+	case uint64:
+		return e.WriteUint64(vv, offset), nil
+	case float32:
+		return e.WriteFloat32(vv, offset), nil
+	case float64:
+		return e.WriteFloat64(vv, offset), nil
+	case string:
+		return e.WriteString(vv, offset), nil
+	case []byte:
+		if vv == nil {
+			return e.WriteNil(offset), nil
+		}
+		offset = e.WriteSliceLength(len(vv), offset, true)
+		return offset + copy(e.d[offset:], vv), nil
+	case complex64:
+		return e.WriteComplex64(vv, offset), nil
+	case complex128:
+		return e.WriteComplex128(vv, offset), nil
+	case time.Time:
+		return e.WriteTime(vv, offset), nil
+
+	case []interface{}:
+		if vv == nil {
+			return e.WriteNil(offset), nil
+		}
+		offset = e.WriteSliceLength(len(vv), offset, false)
+		for _, elm := range vv {
+			o, err := e.writeInterface(elm, offset, asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			offset = o
+		}
+		return offset, nil
+
+	case map[string]interface{}:
+		if vv == nil {
+			return e.WriteNil(offset), nil
+		}
+		offset = e.WriteMapLength(len(vv), offset)
+		keys, ok := k.take().([]string)
+		if !ok {
+			for key := range vv {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			offset = e.WriteString(key, offset)
+			o, err := e.writeInterface(vv[key], offset, asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			offset = o
+		}
+		return offset, nil
+	}
+
+	return e.writeValue(reflect.ValueOf(v), offset, asArray, k)
+}
+
+func (e *Encoder) writeValue(rv reflect.Value, offset int, asArray bool, k *kept) (int, error) {
+	if o, ok, err := e.writeExtValue(rv, offset); ok {
+		return o, err
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return e.WriteBool(rv.Bool(), offset), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.writeInt(rv.Int(), offset), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.writeUint(rv.Uint(), offset), nil
+	case reflect.Float32:
+		return e.WriteFloat32(float32(rv.Float()), offset), nil
+	case reflect.Float64:
+		return e.WriteFloat64(rv.Float(), offset), nil
+	case reflect.String:
+		return e.WriteString(rv.String(), offset), nil
+	case reflect.Complex64:
+		return e.WriteComplex64(complex64(rv.Complex()), offset), nil
+	case reflect.Complex128:
+		return e.WriteComplex128(rv.Complex(), offset), nil
+
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return e.WriteNil(offset), nil
+		}
+		return e.writeValue(rv.Elem(), offset, asArray, k)
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return e.WriteNil(offset), nil
+		}
+		isChildTypeByte := rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8
+		offset = e.WriteSliceLength(rv.Len(), offset, isChildTypeByte)
+		if isChildTypeByte {
+			return offset + copy(e.d[offset:], rv.Bytes()), nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			o, err := e.writeValue(rv.Index(i), offset, asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			offset = o
+		}
+		return offset, nil
+
+	case reflect.Map:
+		if rv.IsNil() {
+			return e.WriteNil(offset), nil
+		}
+		offset = e.WriteMapLength(rv.Len(), offset)
+		keys, ok := k.take().([]reflect.Value)
+		if !ok {
+			keys = rv.MapKeys()
+		}
+		for _, key := range keys {
+			o, err := e.writeValue(key, offset, asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			o, err = e.writeValue(rv.MapIndex(key), o, asArray, k)
+			if err != nil {
+				return 0, err
+			}
+			offset = o
+		}
+		return offset, nil
+	}
+
+	if rv.Type() == typeTime {
+		return e.WriteTime(rv.Interface().(time.Time), offset), nil
+	}
+
+	b, ok := k.take().([]byte)
+	if !ok {
+		var err error
+		if b, err = e.encodeStruct(rv.Interface(), asArray); err != nil {
+			return 0, err
+		}
+	}
+	return offset + copy(e.d[offset:], b), nil
+}
@@ -1,34 +1,54 @@
 package enc
 
-//const fixedSize = 32767
-//
-//type bufferPool struct {
-//	pool sync.Pool
-//}
-//
-//var empty = make([]byte, fixedSize)
-//
-//var bufPool = bufferPool{
-//	pool: sync.Pool{
-//		New: func() interface{} {
-//			buf := new(bytes.Buffer)
-//			buf.Grow(fixedSize)
-//			buf.Write(empty)
-//			return buf
-//		},
-//	},
-//}
-//
-//func (b *bufferPool) Get(size int) *bytes.Buffer {
-//	buf := b.pool.Get().(*bytes.Buffer)
-//	if buf.Len() < size {
-//		buf.Grow(size - buf.Len())
-//		buf.Write(make([]byte, size-buf.Len()))
-//	}
-//	return buf
-//}
-//
-//func (b *bufferPool) Put(buf *bytes.Buffer) {
-//	//buf.Reset()
-//	b.pool.Put(buf)
-//}
+import (
+	"math/bits"
+	"sync"
+	"unsafe"
+)
+
+// buffers are pooled by size classes, which are 4 steps between powers of 2 so that at most 25% of a buffer is unused.
+// larger buffers are not pooled.
+const (
+	minPoolShift = 6  // 64 bytes
+	maxPoolShift = 24 // 16 MiB
+)
+
+// pools hold pointers to the first bytes of buffers, which are stored in interface{} without allocation.
+var pools [1 + (maxPoolShift-minPoolShift)*4]sync.Pool
+
+// sizeClass returns the index and the capacity of the smallest class holding size bytes.
+func sizeClass(size int) (int, int, bool) {
+	if size <= 1<<minPoolShift {
+		return 0, 1 << minPoolShift, true
+	}
+	// 2^(shift-1) < size <= 2^shift
+	shift := bits.Len(uint(size - 1))
+	if shift > maxPoolShift {
+		return 0, 0, false
+	}
+	step := 1 << (shift - 3)
+	n := (size + step - 1) / step // 5 to 8
+	return 1 + (shift-minPoolShift-1)*4 + n - 5, n * step, true
+}
+
+// getBytes returns size bytes with the capacity of the size class.
+func getBytes(size int) ([]byte, bool) {
+	i, c, ok := sizeClass(size)
+	if !ok {
+		return make([]byte, size), false
+	}
+	if p, ok := pools[i].Get().(unsafe.Pointer); ok {
+		return unsafe.Slice((*byte)(p), c)[:size], true
+	}
+	return make([]byte, size, c), true
+}
+
+// PutBytes returns b got from the pool. b must not be used after that.
+// the owner of b is not known, and any slice with the capacity of a size class is pooled. slices of other capacities are ignored.
+func PutBytes(b []byte) {
+	i, c, ok := sizeClass(cap(b))
+	if !ok || c != cap(b) {
+		return
+	}
+	pools[i].Put(unsafe.Pointer(unsafe.SliceData(b[:c])))
+}
@@ -1,6 +1,8 @@
 package enc
 
 import (
+	"math"
+
 	"github.com/shamaton/msgpack/def"
 )
 
@@ -16,6 +18,17 @@ func (e *Encoder) CalcStructHeader32(fieldNum int) int {
 	return def.Byte1 + def.Byte4
 }
 
+// CalcStructHeader is used when the number of fields is known at runtime.
+func (e *Encoder) CalcStructHeader(fieldNum int) int {
+	switch {
+	case fieldNum <= 0x0f:
+		return e.CalcStructHeaderFix(fieldNum)
+	case fieldNum <= math.MaxUint16:
+		return e.CalcStructHeader16(fieldNum)
+	}
+	return e.CalcStructHeader32(fieldNum)
+}
+
 func (e *Encoder) WriteStructHeaderFixAsArray(fieldNum, offset int) int {
 	offset = e.setByte1Int(def.FixArray+fieldNum, offset)
 	return offset
@@ -49,3 +62,14 @@ func (e *Encoder) WriteStructHeader32AsMap(fieldNum, offset int) int {
 	offset = e.setByte4Int(fieldNum, offset)
 	return offset
 }
+
+// WriteStructHeaderAsMap is used when the number of fields is known at runtime.
+func (e *Encoder) WriteStructHeaderAsMap(fieldNum, offset int) int {
+	switch {
+	case fieldNum <= 0x0f:
+		return e.WriteStructHeaderFixAsMap(fieldNum, offset)
+	case fieldNum <= math.MaxUint16:
+		return e.WriteStructHeader16AsMap(fieldNum, offset)
+	}
+	return e.WriteStructHeader32AsMap(fieldNum, offset)
+}
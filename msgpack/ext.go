@@ -0,0 +1,48 @@
+package msgpack
+
+import (
+	"fmt"
+
+	"github.com/shamaton/msgpackgen/msgpack/dec"
+	"github.com/shamaton/msgpackgen/msgpack/enc"
+)
+
+// Ext encodes values of T as the data of an ext type, and decodes them.
+type Ext[T any] interface {
+	enc.ExtEncoder[T]
+	dec.ExtDecoder[T]
+}
+
+// RegisterExt registers x for the ext type code, which is from 0 to 127.
+// generated code calls x for fields tagged with ext=code and for types with the directive "ext code".
+// values of T in interface{} are encoded with x as well, and the ext type is decoded to interface{} with x.
+// it must be called before encoding and decoding.
+func RegisterExt[T any](code int8, x Ext[T]) error {
+	if code < 0 {
+		return fmt.Errorf("msgpackgen : ext type %d is reserved", code)
+	}
+	if err := enc.SetExtEncoder[T](code, x); err != nil {
+		return err
+	}
+	dec.SetExtDecoder[T](code, x)
+	return nil
+}
+
+// SetComplexTypeCode sets the ext type of complex64 and complex128 for encoding and decoding.
+// the type of timestamp and registered ext types can not be used.
+// resolvers with their own ComplexTypeCode keep it, and types processed by shamaton/msgpack
+// instead of generated code keep its default type.
+func SetComplexTypeCode(code int8) error {
+	if err := enc.CheckComplexTypeCode(code); err != nil {
+		return err
+	}
+	if err := dec.SetComplexTypeCode(code); err != nil {
+		return err
+	}
+	return enc.SetComplexTypeCode(code)
+}
+
+// ComplexTypeCode returns the ext type of complex64 and complex128.
+func ComplexTypeCode() int8 {
+	return enc.ComplexTypeCode()
+}
@@ -2,25 +2,33 @@ package msgpack
 
 import (
 	"github.com/shamaton/msgpack"
+	"github.com/shamaton/msgpackgen/msgpack/dec"
+	"github.com/shamaton/msgpackgen/msgpack/enc"
 )
 
 type (
 	EncResolver func(i interface{}) ([]byte, error)
 	DecResolver func(data []byte, i interface{}) (bool, error)
+	// AppendResolver appends the encoded i to dst with e, which holds the options of the call.
+	// it returns nil if the type of i is not resolved.
+	AppendResolver func(e *enc.Encoder, dst []byte, i interface{}) ([]byte, error)
+	// DecoderResolver decodes the data of d, which holds the options of the call.
+	DecoderResolver func(d *dec.Decoder, i interface{}) (bool, error)
 )
 
-var (
-	encAsMapResolver EncResolver = func(i interface{}) ([]byte, error) {
-		return nil, nil
-	}
-	encAsArrayResolver = encAsMapResolver
-
-	decAsMapResolver DecResolver = func(data []byte, i interface{}) (bool, error) {
-		return false, nil
-	}
-	decAsArrayResolver = decAsMapResolver
-)
+func init() {
+	// values in interface{} fields are encoded with generated resolvers as well.
+	// encoders of a Codec encode them with its options instead.
+	enc.SetStructEncoder(func(v interface{}, asArray bool) ([]byte, error) {
+		if asArray {
+			return EncodeAsArray(v)
+		}
+		return EncodeAsMap(v)
+	})
+}
 
+// SetStructAsArray sets the layout of Encode and Decode.
+// it is shared with shamaton/msgpack, so use Options to set the layout per call.
 func SetStructAsArray(on bool) {
 	msgpack.StructAsArray = on
 }
@@ -29,68 +37,81 @@ func StructAsArray() bool {
 	return msgpack.StructAsArray
 }
 
+// SetDecodeLimits sets the limits used by generated decoders to read untrusted data.
+// types decoded without generated code are not limited.
+func SetDecodeLimits(l dec.Limits) {
+	dec.SetDefaultLimits(l)
+}
+
+// DecodeLimits returns the limits used by generated decoders.
+func DecodeLimits() dec.Limits {
+	return dec.DefaultLimits()
+}
+
+// SetResolver replaces the resolver set by the previous call.
+//
+// Deprecated: use Register, which keeps resolvers of other packages.
 func SetResolver(encAsMap, encAsArray EncResolver, decAsMap, decAsArray DecResolver) {
-	encAsMapResolver = encAsMap
-	encAsArrayResolver = encAsArray
-	decAsMapResolver = decAsMap
-	decAsArrayResolver = decAsArray
+	_ = Register(Resolver{
+		Name:          "SetResolver",
+		EncodeAsMap:   encAsMap,
+		EncodeAsArray: encAsArray,
+		DecodeAsMap:   decAsMap.decoderResolver(),
+		DecodeAsArray: decAsArray.decoderResolver(),
+	})
+}
+
+// decoderResolver adapts r, which ignores the options of decoders.
+func (r DecResolver) decoderResolver() DecoderResolver {
+	return func(d *dec.Decoder, i interface{}) (bool, error) {
+		return r(d.Data(), i)
+	}
 }
 
 // Encode returns the MessagePack-encoded byte array of v.
 func Encode(v interface{}) ([]byte, error) {
-	if StructAsArray() {
-		return EncodeAsArray(v)
-	}
-	return EncodeAsMap(v)
+	return defaultCodec().Encode(v)
 }
 
 func EncodeAsMap(v interface{}) ([]byte, error) {
-	if b, err := encAsMapResolver(v); err != nil {
-		return nil, err
-	} else if b != nil {
-		return b, nil
-	}
-
-	return msgpack.EncodeStructAsMap(v)
+	return defaultCodec().encode(v, false)
 }
 
 func EncodeAsArray(v interface{}) ([]byte, error) {
-	if b, err := encAsArrayResolver(v); err != nil {
-		return nil, err
-	} else if b != nil {
-		return b, nil
-	}
+	return defaultCodec().encode(v, true)
+}
+
+// AppendEncode appends the MessagePack-encoded v to dst and returns the extended slice.
+// generated types are encoded in the spare capacity of dst without allocation, if it is enough.
+func AppendEncode(dst []byte, v interface{}) ([]byte, error) {
+	return defaultCodec().AppendEncode(dst, v)
+}
+
+func AppendEncodeAsMap(dst []byte, v interface{}) ([]byte, error) {
+	return defaultCodec().appendEncode(dst, v, false)
+}
 
-	return msgpack.EncodeStructAsArray(v)
+func AppendEncodeAsArray(dst []byte, v interface{}) ([]byte, error) {
+	return defaultCodec().appendEncode(dst, v, true)
+}
+
+// Release returns the bytes encoded by Encode, EncodeAsMap or EncodeAsArray to the pool, so that the next encoding reuses them.
+// b must not be used after that, nor strings decoded from b. calling Release is optional.
+// results of AppendEncode must not be released, because they may be in the memory of dst.
+func Release(b []byte) {
+	enc.PutBytes(b)
 }
 
 // Decode analyzes the MessagePack-encoded data and stores
 // the result into the pointer of v.
 func Decode(data []byte, v interface{}) error {
-	if StructAsArray() {
-		return DecodeAsArray(data, v)
-	}
-	return DecodeAsMap(data, v)
+	return defaultCodec().Decode(data, v)
 }
 
 func DecodeAsMap(data []byte, v interface{}) error {
-	b, err := decAsMapResolver(data, v)
-	if err != nil {
-		return err
-	}
-	if b {
-		return nil
-	}
-	return msgpack.DecodeStructAsMap(data, v)
+	return defaultCodec().decode(data, v, false)
 }
 
 func DecodeAsArray(data []byte, v interface{}) error {
-	b, err := decAsArrayResolver(data, v)
-	if err != nil {
-		return err
-	}
-	if b {
-		return nil
-	}
-	return msgpack.DecodeStructAsArray(data, v)
+	return defaultCodec().decode(data, v, true)
 }
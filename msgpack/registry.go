@@ -0,0 +1,156 @@
+package msgpack
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/shamaton/msgpackgen/msgpack/enc"
+)
+
+// Resolver is a set of functions generated for types in a package.
+// functions return nil or false when the type of i is not resolved.
+type Resolver struct {
+	// registering the same name again replaces the resolver
+	Name string
+	// types resolved by the resolver. a type can not be registered by two resolvers.
+	// resolvers without types are tried for all types not registered.
+	Types []reflect.Type
+	// types which other resolvers may also resolve, such as anonymous structs,
+	// instances of generic types and types declared in other packages.
+	// every resolver sharing a type is tried in order of registration.
+	SharedTypes []reflect.Type
+	// import paths of the packages whose types are generated by the resolver
+	Packages []string
+	// strict resolvers return errors for types of Packages not registered.
+	// the types of other packages are left to the other resolvers.
+	Strict bool
+	// ext type of complex64 and complex128 written and read by the resolver.
+	// nil uses the type set by SetComplexTypeCode. RegisterExt can not use the type after registration.
+	ComplexTypeCode *int8
+
+	EncodeAsMap, EncodeAsArray EncResolver
+	DecodeAsMap, DecodeAsArray DecoderResolver
+	// nil for resolvers which can not append
+	AppendAsMap, AppendAsArray AppendResolver
+}
+
+// registry is replaced on registration, so that it is read without lock.
+type registry struct {
+	resolvers []*Resolver
+	// the resolver of each type in a slice of 1 element
+	types map[reflect.Type][]*Resolver
+	// the resolvers sharing each type
+	shared map[reflect.Type][]*Resolver
+	// resolvers tried for types not registered
+	others []*Resolver
+	// strict resolvers by the packages of their types
+	strict map[string][]*Resolver
+}
+
+var (
+	registryMu      sync.Mutex
+	currentRegistry atomic.Pointer[registry]
+)
+
+// Register adds r to the resolvers used by Encode and Decode.
+// generated code calls it from RegisterGeneratedResolver.
+func Register(r Resolver) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	next := &registry{
+		types:  map[reflect.Type][]*Resolver{},
+		shared: map[reflect.Type][]*Resolver{},
+		strict: map[string][]*Resolver{},
+	}
+	if current := currentRegistry.Load(); current != nil {
+		for _, old := range current.resolvers {
+			if old.Name != r.Name {
+				next.add(old)
+			}
+		}
+	}
+
+	for _, t := range r.Types {
+		if old, found := next.types[t]; found {
+			return fmt.Errorf("msgpackgen : %v is registered by both %s and %s", t, old[0].Name, r.Name)
+		}
+	}
+	if r.ComplexTypeCode != nil {
+		if err := enc.UseComplexTypeCode(*r.ComplexTypeCode); err != nil {
+			return err
+		}
+	}
+	next.add(&r)
+	currentRegistry.Store(next)
+	return nil
+}
+
+// complexTypeCode returns the ext type of complex64 and complex128 of r.
+func (r *Resolver) complexTypeCode() int8 {
+	if r.ComplexTypeCode != nil {
+		return *r.ComplexTypeCode
+	}
+	return ComplexTypeCode()
+}
+
+// appendEncode appends with appendFunc, or with encodeFunc for resolvers which can not append.
+func (r *Resolver) appendEncode(e *enc.Encoder, dst []byte, v interface{}, appendFunc AppendResolver, encodeFunc EncResolver) ([]byte, error) {
+	if appendFunc != nil {
+		e.SetComplexTypeCode(r.complexTypeCode())
+		return appendFunc(e, dst, v)
+	}
+	b, err := encodeFunc(v)
+	if err != nil || b == nil || dst == nil {
+		return b, err
+	}
+	return append(dst, b...), nil
+}
+
+func (reg *registry) add(r *Resolver) {
+	reg.resolvers = append(reg.resolvers, r)
+	for _, t := range r.Types {
+		reg.types[t] = []*Resolver{r}
+	}
+	for _, t := range r.SharedTypes {
+		reg.shared[t] = append(reg.shared[t], r)
+	}
+	if len(r.Types) == 0 && len(r.SharedTypes) == 0 {
+		reg.others = append(reg.others, r)
+	} else if r.Strict {
+		for _, pkg := range r.Packages {
+			reg.strict[pkg] = append(reg.strict[pkg], r)
+		}
+	}
+}
+
+// resolvers returns the resolvers to be tried for v.
+// pointers are resolved by the resolver of the type pointed.
+// types not registered are tried by the other resolvers, and then by strict resolvers of their packages.
+func resolvers(v interface{}) []*Resolver {
+	reg := currentRegistry.Load()
+	if reg == nil {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	for ; t != nil; t = t.Elem() {
+		if rs, found := reg.types[t]; found {
+			return rs
+		}
+		if rs, found := reg.shared[t]; found {
+			return rs
+		}
+		if t.Kind() != reflect.Pointer {
+			break
+		}
+	}
+	if t == nil || t.PkgPath() == "" {
+		return reg.others
+	}
+	if strict := reg.strict[t.PkgPath()]; len(strict) > 0 {
+		return append(reg.others[:len(reg.others):len(reg.others)], strict...)
+	}
+	return reg.others
+}
@@ -0,0 +1,148 @@
+package msgpack
+
+import (
+	"errors"
+	"io"
+
+	"github.com/shamaton/msgpackgen/msgpack/dec"
+)
+
+// Encoder writes MessagePack-encoded values to a stream in sequence.
+type Encoder struct {
+	w io.Writer
+	// nil encodes with the default options
+	c *Codec
+	// reused for each value
+	buf []byte
+}
+
+// NewEncoder returns a new Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// NewEncoder returns a new Encoder writing to w with the options of c.
+func (c *Codec) NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, c: c}
+}
+
+// Encode writes the MessagePack-encoded v to the stream.
+func (e *Encoder) Encode(v interface{}) error {
+	c := e.c
+	if c == nil {
+		c = defaultCodec()
+	}
+	b, err := c.AppendEncode(e.buf[:0], v)
+	if err != nil {
+		return err
+	}
+	e.buf = b
+	_, err = e.w.Write(b)
+	return err
+}
+
+const (
+	minBufferSize         = 4096
+	maxConsecutiveNoReads = 100
+)
+
+// Decoder reads values from a stream of concatenated MessagePack messages.
+type Decoder struct {
+	r io.Reader
+	// nil decodes with the default options
+	c   *Codec
+	buf []byte
+	// unread bytes are buf[start:end]
+	start, end int
+	// error of r, returned once when more bytes are needed
+	err error
+	// error framing a message, returned by every call after it
+	framingErr error
+}
+
+// NewDecoder returns a new Decoder reading from r.
+// data is read into an internal buffer, so that r may be read beyond the last message decoded.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// NewDecoder returns a new Decoder reading from r with the options of c.
+func (c *Codec) NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, c: c}
+}
+
+// Decode reads the next message from the stream and stores the result into the pointer of v.
+// io.EOF is returned at the end of the stream, and io.ErrUnexpectedEOF when the stream ends in a message.
+// when a message is malformed or over the decode limits, the rest of the stream can not be framed,
+// so that the error is returned by every call after it.
+func (d *Decoder) Decode(v interface{}) error {
+	if d.framingErr != nil {
+		return d.framingErr
+	}
+	c := d.c
+	if c == nil {
+		c = defaultCodec()
+	}
+
+	need := 1
+	for {
+		if err := d.fill(need); err != nil {
+			if err == io.EOF && d.end > d.start {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+
+		// the message is framed before decoding, so that a short buffer is read more
+		md := c.newDecoder(d.buf[d.start:d.end])
+		n, err := md.Validate()
+		var sbe *dec.ShortBufferError
+		if errors.As(err, &sbe) {
+			need = sbe.Offset + sbe.Need
+			continue
+		}
+		if err != nil {
+			d.framingErr = err
+			return err
+		}
+
+		d.start += n
+		return c.decodeWith(md, v, c.opts.StructAsArray)
+	}
+}
+
+// fill reads the stream until n bytes are unread.
+func (d *Decoder) fill(n int) error {
+	noReads := 0
+	for d.end-d.start < n {
+		if err := d.err; err != nil {
+			d.err = nil
+			return err
+		}
+		if d.end == len(d.buf) {
+			d.grow()
+		}
+
+		m, err := d.r.Read(d.buf[d.end:])
+		d.end += m
+		d.err = err
+
+		if m > 0 {
+			noReads = 0
+		} else if noReads++; noReads >= maxConsecutiveNoReads {
+			return io.ErrNoProgress
+		}
+	}
+	return nil
+}
+
+// grow moves unread bytes to a new buffer with free space.
+// the buffer grows with the data actually read, not with lengths in the data.
+func (d *Decoder) grow() {
+	// read bytes are not overwritten, because decoded strings refer to them
+	unread := d.end - d.start
+	buf := make([]byte, max(2*unread, minBufferSize))
+	copy(buf, d.buf[d.start:d.end])
+	d.buf = buf
+	d.start, d.end = 0, unread
+}
@@ -1,19 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
 	"flag"
 	"fmt"
+	"image"
+	"io"
 	"math"
 	"math/rand"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
+	"unsafe"
 
+	"github.com/shamaton/msgpack/def"
 	"github.com/shamaton/msgpackgen/msgpack"
+	"github.com/shamaton/msgpackgen/msgpack/dec"
+	"github.com/shamaton/msgpackgen/msgpack/enc"
 	define2 "github.com/shamaton/msgpackgen/testdata/define"
 	"github.com/shamaton/msgpackgen/testdata/define/define"
+	"github.com/shamaton/msgpackgen/testdata/define/v2"
 )
 
 var fromMain = false
@@ -613,6 +626,1408 @@ func TestStruct(t *testing.T) {
 	}
 }
 
+func TestResolve(t *testing.T) {
+	v := TestingResolve{
+		Holder:    define.TimeHolder{Time: define.Time{Int: rand.Int()}},
+		Versioned: versioned.Versioned{Int: rand.Int()},
+	}
+	var v1, v2 TestingResolve
+	if err := _checkValue(v, &v1, &v2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNamed(t *testing.T) {
+	status := Status(rand.Intn(math.MaxUint8))
+	v := TestingNamed{
+		Status:   status,
+		ID:       UserID(rand.Int63()),
+		Name:     "named",
+		Raw:      Raw{1, 2, 3},
+		IDs:      IDs{UserID(rand.Int63()), UserID(rand.Int63())},
+		Tags:     Tags{"a", "b"},
+		Pair:     Pair{"x", "y"},
+		Labels:   Labels{"label": Status(1)},
+		Level:    define.Level(-1),
+		PStatus:  &status,
+		Statuses: []Status{1, 2, 3},
+		ByID:     map[UserID]Tags{1: {"c"}},
+		Nested:   []map[Name]*IDs{{"ids": &IDs{1, 2}}, nil},
+		Grid:     [2][]Pair{{{"a", "b"}}, {}},
+	}
+	var v1, v2 TestingNamed
+	if err := _checkValue(v, &v1, &v2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAnonymous(t *testing.T) {
+	v := TestingAnonymous{}
+	v.Inner.Int = rand.Int()
+	v.Inner.Name = "inner"
+	v.Inner.Deep.Time = time.Unix(rand.Int63n(1<<32), 0)
+	v.Inner.Deep.Child = define.B{Int: rand.Int()}
+	v.Slice = []struct{ Int int }{{Int: 1}, {Int: 2}}
+	v.Map = map[string]*struct{ Inside }{"a": {Inside{Int: 3}}, "b": nil}
+	v.Pointer = &struct{ Ints [2]int }{Ints: [2]int{4, 5}}
+	v.Same.Int = rand.Int()
+
+	var v1, v2 TestingAnonymous
+	if err := _checkValue(v, &v1, &v2); err != nil {
+		t.Error(err)
+	}
+
+	// anonymous struct itself
+	s := struct{ Int int }{Int: rand.Int()}
+	var s1, s2 struct{ Int int }
+	if err := _checkValue(s, &s1, &s2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInterface(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	var p interface{} = "pointer"
+	v := TestingInterface{
+		Int:     -1,
+		Any:     Status(2),
+		Slice:   []interface{}{"a", 1.5, true, nil, []byte{1, 2}, now, complex64(1 + 2i)},
+		Map:     map[string]interface{}{"a": 1, "b": []int{2}, "c": map[string]interface{}{"d": Inside{Int: 3}}, "e": Inside{Int: 5}},
+		Struct:  &Inside{Int: 4},
+		Pointer: &p,
+	}
+
+	check := func(u TestingInterface, asArray bool) (bool, interface{}, interface{}) {
+		asStruct := func(i uint8) interface{} {
+			if asArray {
+				return []interface{}{i}
+			}
+			return map[interface{}]interface{}{"Int": i}
+		}
+		expected := TestingInterface{
+			Int:     int8(-1),
+			Any:     uint8(2),
+			Slice:   []interface{}{"a", 1.5, true, nil, []byte{1, 2}, now, complex64(1 + 2i)},
+			Map:     map[string]interface{}{"a": uint8(1), "b": []interface{}{uint8(2)}, "c": map[interface{}]interface{}{"d": asStruct(3)}, "e": asStruct(5)},
+			Struct:  asStruct(4),
+			Pointer: &p,
+		}
+		return reflect.DeepEqual(expected, u), expected, u
+	}
+
+	var v1, v2 TestingInterface
+	eq1 := func() (bool, interface{}, interface{}) { return check(v1, false) }
+	eq2 := func() (bool, interface{}, interface{}) { return check(v2, true) }
+	if err := _checkValue(v, &v1, &v2, eq1, eq2); err != nil {
+		t.Error(err)
+	}
+
+	// nil slices and maps are nil as well as in the other types
+	nils := TestingInterface{Int: []byte(nil), Nil: []interface{}(nil), Any: map[string]interface{}(nil), Struct: map[string]int(nil)}
+	for _, asArray := range []bool{false, true} {
+		b, err := msgpack.EncodeWithOptions(nils, msgpack.Options{StructAsArray: asArray})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var u TestingInterface
+		if err := msgpack.DecodeWithOptions(b, &u, msgpack.Options{StructAsArray: asArray}); err != nil {
+			t.Fatal(err)
+		}
+		if u.Int != nil || u.Nil != nil || u.Any != nil || u.Struct != nil {
+			t.Error("nil is not decoded", u)
+		}
+	}
+
+	// structs in interface{} are encoded once, and maps are written in the order calculated
+	calls := 0
+	e := enc.NewEncoder()
+	e.SetStructEncoder(func(v interface{}, asArray bool) ([]byte, error) {
+		calls++
+		return msgpack.EncodeAsMap(v)
+	})
+	var nested interface{} = map[string]interface{}{
+		"a": Inside{Int: 1},
+		"b": []interface{}{Inside{Int: 2}},
+		"c": map[int]Inside{3: {Int: 3}, 4: {Int: 4}, 5: {Int: 5}},
+	}
+	size, err := e.CalcInterface(nested, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.MakeBytes(size)
+	if offset, err := e.WriteInterface(nested, 0, false); err != nil || offset != size {
+		t.Fatal("written size is different", offset, size, err)
+	}
+	if calls != 5 {
+		t.Errorf("structs are encoded %d times", calls)
+	}
+	decoded, _, err := dec.NewDecoder(e.EncodedBytes()).AsInterface(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asMap := func(i uint8) interface{} { return map[interface{}]interface{}{"Int": i} }
+	expected := map[interface{}]interface{}{
+		"a": asMap(1),
+		"b": []interface{}{asMap(2)},
+		"c": map[interface{}]interface{}{uint8(3): asMap(3), uint8(4): asMap(4), uint8(5): asMap(5)},
+	}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Error("value different", expected, decoded)
+	}
+
+	// keys of maps in interface{} are comparable. bin keys are decoded as string.
+	var u TestingInterface
+	var de *dec.DecodeError
+	if err := msgpack.DecodeAsMap([]byte{0x81, 0xa3, 'I', 'n', 't', 0x81, 0x91, 0x01, 0x01}, &u); !errors.As(err, &de) {
+		t.Error("error is not decode error", err)
+	}
+	if err := msgpack.DecodeAsMap([]byte{0x81, 0xa3, 'I', 'n', 't', 0x81, def.Bin8, 1, 'a', 0x01}, &u); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(u.Int, map[interface{}]interface{}{"a": uint8(1)}) {
+		t.Error("bin key is not decoded as string", u.Int)
+	}
+}
+
+func TestInline(t *testing.T) {
+	v := TestingInline{Int: rand.Int(), Nested: Inside{Int: rand.Int()}}
+	v.Child = "child"
+	v.Deep = rand.Int()
+
+	var v1, v2 TestingInline
+	if err := _checkValue(v, &v1, &v2); err != nil {
+		t.Error(err)
+	}
+
+	b, err := msgpack.EncodeAsMap(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, _, err := dec.NewDecoder(b).AsInterface(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for k := range m.(map[interface{}]interface{}) {
+		keys = append(keys, k.(string))
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"Deep", "Int", "Nested", "child"}) {
+		t.Errorf("keys are wrong %v", keys)
+	}
+}
+
+func TestGeneric(t *testing.T) {
+	v := TestingGeneric{
+		Ints:    Page[int]{Items: []int{1, 2, 3}, Next: "next"},
+		Insides: Page[Inside]{Items: []Inside{{Int: rand.Int()}}},
+		Nested:  Page[Page[string]]{Items: []Page[string]{{Items: []string{"a"}}}},
+		Pair:    &KeyValue[string, []Status]{Key: "key", Value: []Status{1}},
+		Outer:   define2.Wrapper[define.B]{Value: define.B{Int: rand.Int()}},
+		List:    List[Name]{"a", "b"},
+	}
+	var v1, v2 TestingGeneric
+	if err := _checkValue(v, &v1, &v2); err != nil {
+		t.Error(err)
+	}
+
+	// listed instance
+	p := Page[float64]{Items: []float64{rand.Float64()}}
+	var p1, p2 Page[float64]
+	if err := _checkValue(p, &p1, &p2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	v := TestingIndex{A: rand.Int(), B: "b", C: rand.Int(), D: true}
+	var v1, v2 TestingIndex
+	if err := _checkValue(v, &v1, &v2); err != nil {
+		t.Error(err)
+	}
+
+	b, err := msgpack.EncodeAsArray(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _, err := dec.NewDecoder(b).AsInterface(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// integers are compared as strings, because the type depends on the value
+	want := fmt.Sprint([]interface{}{"b", v.C, v.A, nil, nil, nil, true})
+	if got := fmt.Sprint(a); got != want {
+		t.Errorf("slots are wrong %s, %s", got, want)
+	}
+}
+
+func TestOmitEmpty(t *testing.T) {
+	keys := func(v TestingOmitEmpty) []string {
+		b, err := msgpack.EncodeAsMap(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, _, err := dec.NewDecoder(b).AsInterface(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for k := range m.(map[interface{}]interface{}) {
+			keys = append(keys, k.(string))
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	empty := TestingOmitEmpty{}
+	if got := keys(empty); !reflect.DeepEqual(got, []string{"Always", "Inside"}) {
+		t.Errorf("empty fields are encoded %v", got)
+	}
+	b, err := msgpack.EncodeAsMap(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e TestingOmitEmpty
+	if err := msgpack.DecodeAsMap(b, &e); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(empty, e) {
+		t.Errorf("not equal %v, %v", empty, e)
+	}
+
+	v := TestingOmitEmpty{
+		Int:       rand.Int(),
+		Uint:      1,
+		Float:     rand.Float64(),
+		Bool:      true,
+		String:    "string",
+		Status:    2,
+		Slice:     []int{1},
+		Map:       map[string]int{"a": 1},
+		Pointer:   &Inside{Int: rand.Int()},
+		Interface: "interface",
+		Time:      time.Unix(rand.Int63n(1<<32), 0),
+		Always:    rand.Int(),
+	}
+	if got := keys(v); len(got) != 13 {
+		t.Errorf("fields are omitted %v", got)
+	}
+	var v1, v2 TestingOmitEmpty
+	if err := _checkValue(v, &v1, &v2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnknownKey(t *testing.T) {
+	// written by a newer writer
+	newer := InsideNewer{
+		Unknown: map[string][]interface{}{"Nested": {int64(1), "a", nil}},
+		Int:     rand.Int(),
+		Bytes:   []byte{1, 2},
+	}
+	b, err := msgpack.EncodeAsMap(newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v Inside
+	if err := msgpack.DecodeAsMap(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Int != newer.Int {
+		t.Errorf("value is wrong %v, %v", v, newer)
+	}
+
+	// missing fields are left at zero
+	b, err = msgpack.EncodeAsMap(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n InsideNewer
+	if err := msgpack.DecodeAsMap(b, &n); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n, InsideNewer{Int: v.Int}) {
+		t.Errorf("value is wrong %v", n)
+	}
+}
+
+func TestEvolvable(t *testing.T) {
+	newer := EvolvableNew{A: rand.Int(), B: "b", C: []int{1}, D: &Inside{Int: rand.Int()}}
+	var n1, n2 EvolvableNew
+	if err := _checkValue(newer, &n1, &n2); err != nil {
+		t.Error(err)
+	}
+
+	// longer array
+	b, err := msgpack.EncodeAsArray(newer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var older EvolvableOld
+	if err := msgpack.DecodeAsArray(b, &older); err != nil {
+		t.Fatal(err)
+	}
+	if older.A != newer.A || older.B != newer.B {
+		t.Errorf("value is wrong %v, %v", older, newer)
+	}
+
+	// shorter array
+	b, err = msgpack.EncodeAsArray(older)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n EvolvableNew
+	if err := msgpack.DecodeAsArray(b, &n); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(n, EvolvableNew{A: older.A, B: older.B}) {
+		t.Errorf("value is wrong %v", n)
+	}
+
+	// not evolvable
+	b, err = msgpack.EncodeAsArray(InsideNewer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v Inside
+	if err := msgpack.DecodeAsArray(b, &v); err == nil {
+		t.Error("error should occur")
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	now := time.Now()
+	var i interface{} = "pointer"
+	values := []interface{}{
+		TestingValue{Int: -1, Uint64: math.MaxUint64, Float64: math.Pi, String: "string", Bool: true, Complex128: complex(1, 2)},
+		TestingTime{Time: now, TimePointer: &now},
+		TestingInterface{Int: 1, Slice: []interface{}{"a", 1.5}, Map: map[string]interface{}{"a": []byte{1}}, Pointer: &i},
+		TestingOmitEmpty{Slice: []int{1, 2}, Map: map[string]int{"a": 1}, Pointer: &Inside{Int: 1}},
+		EvolvableNew{A: 1, B: "b", C: []int{1}},
+	}
+
+	for _, v := range values {
+		for _, asArray := range []bool{true, false} {
+			var b []byte
+			var err error
+			if asArray {
+				b, err = msgpack.EncodeAsArray(v)
+			} else {
+				b, err = msgpack.EncodeAsMap(v)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// prefixes of large data are sampled
+			for l := 0; l < len(b); l += len(b)/1000 + 1 {
+				ptr := reflect.New(reflect.TypeOf(v)).Interface()
+				if asArray {
+					err = msgpack.DecodeAsArray(b[:l], ptr)
+				} else {
+					err = msgpack.DecodeAsMap(b[:l], ptr)
+				}
+				if err == nil {
+					t.Errorf("%T: truncated data %d / %d is decoded", v, l, len(b))
+				}
+			}
+
+			var sbe *dec.ShortBufferError
+			ptr := reflect.New(reflect.TypeOf(v)).Interface()
+			err = msgpack.DecodeAsMap(b[:len(b)-1], ptr)
+			if asArray {
+				err = msgpack.DecodeAsArray(b[:len(b)-1], ptr)
+			}
+			if !errors.Is(err, io.ErrUnexpectedEOF) || !errors.As(err, &sbe) || sbe.Len != len(b)-1 {
+				t.Errorf("%T: error is not short buffer %v", v, err)
+			}
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	v := TestingValue{Int: -1, String: "string", Complex128: complex(1, 2)}
+	b, err := msgpack.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// data is cut to the first value
+	d := dec.NewDecoder(append(b, def.Nil))
+	if n, err := d.Validate(); err != nil || n != len(b) || len(d.Data()) != len(b) {
+		t.Error("value is not validated", n, len(d.Data()), err)
+	}
+	var sbe *dec.ShortBufferError
+	if _, err := dec.NewDecoder(b[:len(b)-1]).Validate(); !errors.As(err, &sbe) {
+		t.Error("truncated data is validated", err)
+	}
+
+	// data not matching the types is not read over the validated value
+	bin := []byte{0x81, 0xa5, 'S', 'l', 'i', 'c', 'e', def.Bin8, 1, def.Uint64}
+	var o TestingOmitEmpty
+	if err := msgpack.NewDecoder(bytes.NewReader(bin)).Decode(&o); err == nil {
+		t.Error("bin is decoded to []int")
+	}
+	if err := msgpack.DecodeAsMap(bin, &o); err == nil {
+		t.Error("bin is decoded to []int")
+	}
+
+	// framed data is read in another structure by the decoders, and is checked as well
+	testCases := []struct {
+		name string
+		data []byte
+		opts msgpack.Options
+	}{
+		{name: "array as map", data: []byte{0x91, 0xa1, 'N'}},
+		{name: "array of bytes", data: []byte{0x92, 0x91, def.Uint16, def.Uint64, 0, 5}, opts: msgpack.Options{StructAsArray: true}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var s TestingShort
+			err := msgpack.NewCodec(tc.opts).NewDecoder(bytes.NewReader(tc.data)).Decode(&s)
+			if !errors.As(err, &sbe) {
+				t.Error("error is not short buffer", err)
+			}
+		})
+	}
+
+	d = dec.NewDecoder([]byte{def.Ext8, 0, 0xff})
+	if _, err := d.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := d.AsDateTime(0); err == nil {
+		t.Error("ext8 of length 0 is decoded to time")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	defaults := msgpack.DecodeLimits()
+	defer msgpack.SetDecodeLimits(defaults)
+
+	// a slice of 4294967295 elements in 6 bytes is not allocated
+	b := []byte{0x81, 0xa5, 'S', 'l', 'i', 'c', 'e', 0xdd, 0xff, 0xff, 0xff, 0xff}
+	var sbe *dec.ShortBufferError
+	if err := msgpack.DecodeAsMap(b, &TestingOmitEmpty{}); !errors.As(err, &sbe) {
+		t.Error("error is not short buffer", err)
+	}
+
+	var r *Recursive
+	for i := 0; i < 5; i++ {
+		r = &Recursive{Int: i, R: r}
+	}
+	v := TestingOmitEmpty{
+		String:    "string",
+		Slice:     []int{1, 2, 3, 4},
+		Map:       map[string]int{"a": 1},
+		Interface: []interface{}{[]interface{}{1}},
+	}
+
+	testCases := []struct {
+		name   string
+		limits dec.Limits
+		value  interface{}
+		limit  string
+	}{
+		{name: "collection", limits: dec.Limits{MaxCollectionLength: 3}, value: v, limit: "MaxCollectionLength"},
+		{name: "string", limits: dec.Limits{MaxStringLength: 5}, value: v, limit: "MaxStringLength"},
+		{name: "allocation", limits: dec.Limits{MaxAllocation: 16}, value: v, limit: "MaxAllocation"},
+		{name: "depth", limits: dec.Limits{MaxDepth: 4}, value: *r, limit: "MaxDepth"},
+		{name: "interface depth", limits: dec.Limits{MaxDepth: 2}, value: v, limit: "MaxDepth"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, asArray := range []bool{true, false} {
+				b, err := msgpack.EncodeAsMap(tc.value)
+				if asArray {
+					b, err = msgpack.EncodeAsArray(tc.value)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				msgpack.SetDecodeLimits(dec.Limits{})
+				ptr := reflect.New(reflect.TypeOf(tc.value)).Interface()
+				if asArray {
+					err = msgpack.DecodeAsArray(b, ptr)
+				} else {
+					err = msgpack.DecodeAsMap(b, ptr)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				msgpack.SetDecodeLimits(tc.limits)
+				if asArray {
+					err = msgpack.DecodeAsArray(b, ptr)
+				} else {
+					err = msgpack.DecodeAsMap(b, ptr)
+				}
+				var le *dec.LimitError
+				if !errors.As(err, &le) || le.Limit != tc.limit {
+					t.Errorf("error is not %s: %v", tc.limit, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    TestingOrderText
+		path     string
+		expected string
+	}{
+		{
+			name:     "slice",
+			value:    TestingOrderText{Items: []*TestingItemText{nil, {Price: "1"}}},
+			path:     "Items[1].Price",
+			expected: "int",
+		},
+		{
+			name:     "map",
+			value:    TestingOrderText{Prices: map[string]TestingItemText{"a": {Price: "1"}}},
+			path:     `Prices["a"].Price`,
+			expected: "int",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, asArray := range []bool{true, false} {
+				b, err := msgpack.EncodeAsMap(tc.value)
+				if asArray {
+					b, err = msgpack.EncodeAsArray(tc.value)
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var v TestingOrder
+				if asArray {
+					err = msgpack.DecodeAsArray(b, &v)
+				} else {
+					err = msgpack.DecodeAsMap(b, &v)
+				}
+				var de *dec.DecodeError
+				if !errors.As(err, &de) {
+					t.Fatal("error is not decode error", err)
+				}
+				if de.Type != "TestingOrder" || de.Path != tc.path || de.Expected != tc.expected || de.Code != b[de.Offset] {
+					t.Errorf("decode error is wrong: %+v", de)
+				}
+				if want := "TestingOrder." + tc.path; !strings.Contains(err.Error(), want) {
+					t.Errorf("error message %q does not contain %s", err.Error(), want)
+				}
+			}
+		})
+	}
+
+	// causes are kept
+	b, err := msgpack.EncodeAsArray(TestingOrder{Items: []*TestingItem{{Price: 1000}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var de *dec.DecodeError
+	// the last byte of Price and nil of Prices are cut
+	err = msgpack.DecodeAsArray(b[:len(b)-2], &TestingOrder{})
+	if !errors.As(err, &de) || de.Path != "Items[0].Price" || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Error("error is not decode error with short buffer", err)
+	}
+}
+
+func TestAppendEncode(t *testing.T) {
+	v := TestingOrderText{
+		Items:  []*TestingItemText{{Price: "1"}},
+		Prices: map[string]TestingItemText{"a": {Price: "2"}},
+	}
+	prefix := []byte{0x01, 0x02}
+
+	for _, asArray := range []bool{true, false} {
+		encode, appendEncode := msgpack.EncodeAsMap, msgpack.AppendEncodeAsMap
+		if asArray {
+			encode, appendEncode = msgpack.EncodeAsArray, msgpack.AppendEncodeAsArray
+		}
+		want, err := encode(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := appendEncode(prefix, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, append(prefix, want...)) {
+			t.Errorf("appended bytes are wrong %x", b)
+		}
+
+		// the spare capacity is used
+		buf := make([]byte, 0, len(want))
+		item := &TestingItemText{Price: "price"}
+		allocs := testing.AllocsPerRun(100, func() {
+			buf, err = appendEncode(buf[:0], item)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allocs != 0 {
+			t.Errorf("append allocates %v times", allocs)
+		}
+	}
+}
+
+func TestRelease(t *testing.T) {
+	v := &TestingItemText{Price: "price"}
+	b, err := msgpack.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{}, b...)
+	msgpack.Release(b)
+
+	// released bytes are reused
+	allocs := testing.AllocsPerRun(100, func() {
+		b, err = msgpack.Encode(v)
+		msgpack.Release(b)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if allocs != 0 {
+		t.Errorf("encode allocates %v times", allocs)
+	}
+
+	b, err = msgpack.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Errorf("encoded bytes are wrong %x", b)
+	}
+
+	// capacities other than size classes are not pooled
+	other := make([]byte, 10, 100)
+	msgpack.Release(other)
+	for i := 0; i < 10; i++ {
+		if b, _ = msgpack.Encode(v); unsafe.SliceData(b) == unsafe.SliceData(other) {
+			t.Error("bytes of other capacity are reused")
+		}
+	}
+}
+
+func TestMethods(t *testing.T) {
+	v := TestingOrderText{Items: []*TestingItemText{{Price: "1"}}}
+	want, err := msgpack.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// methods are generated, so that they are checked at runtime
+	var i interface{} = v
+	m, ok := i.(encoding.BinaryMarshaler)
+	if !ok {
+		t.Fatal("MarshalBinary is not generated")
+	}
+	b, err := m.MarshalBinary()
+	if err != nil || !bytes.Equal(b, want) {
+		t.Errorf("marshaled bytes are wrong %x %v", b, err)
+	}
+	mm, ok := i.(interface{ MarshalMsgpack() ([]byte, error) })
+	if !ok {
+		t.Fatal("MarshalMsgpack is not generated")
+	}
+	if b, err = mm.MarshalMsgpack(); err != nil || !bytes.Equal(b, want) {
+		t.Errorf("marshaled bytes are wrong %x %v", b, err)
+	}
+
+	var u TestingOrderText
+	i = &u
+	um, ok := i.(encoding.BinaryUnmarshaler)
+	if !ok {
+		t.Fatal("UnmarshalBinary is not generated")
+	}
+	if err = um.UnmarshalBinary(want); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, u) {
+		t.Error("value different", v, u)
+	}
+
+	// methods follow the package setting of the layout
+	global := msgpack.StructAsArray()
+	defer msgpack.SetStructAsArray(global)
+	msgpack.SetStructAsArray(!global)
+	if want, err = msgpack.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = mm.MarshalMsgpack(); err != nil || !bytes.Equal(b, want) {
+		t.Errorf("marshaled bytes are wrong %x %v", b, err)
+	}
+	if err = um.UnmarshalBinary(want); err != nil || !reflect.DeepEqual(v, u) {
+		t.Error("value different", v, u, err)
+	}
+	msgpack.SetStructAsArray(global)
+
+	// the pair of the method declared by the user is not generated
+	methods := reflect.TypeOf(&TestingMethod{})
+	if _, ok := methods.MethodByName("UnmarshalBinary"); ok {
+		t.Error("UnmarshalBinary is generated")
+	}
+	if _, ok := methods.MethodByName("UnmarshalMsgpack"); !ok {
+		t.Error("UnmarshalMsgpack is not generated")
+	}
+	if b, err = (TestingMethod{Int: 12}).MarshalBinary(); err != nil || string(b) != "12" {
+		t.Error("MarshalBinary is overwritten", b, err)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	type registered struct{ Int int }
+	encode := func(i interface{}) ([]byte, error) {
+		switch v := i.(type) {
+		case registered:
+			return []byte{byte(v.Int)}, nil
+		case *registered:
+			return []byte{byte(v.Int)}, nil
+		}
+		return nil, nil
+	}
+	decode := func(d *dec.Decoder, i interface{}) (bool, error) {
+		if v, ok := i.(*registered); ok {
+			v.Int = int(d.Data()[0])
+			return true, nil
+		}
+		return false, nil
+	}
+	r := msgpack.Resolver{
+		Name:          "registry test",
+		Types:         []reflect.Type{reflect.TypeOf(registered{})},
+		EncodeAsMap:   encode,
+		EncodeAsArray: encode,
+		DecodeAsMap:   decode,
+		DecodeAsArray: decode,
+	}
+	if err := msgpack.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	// registering the same name replaces the resolver
+	if err := msgpack.Register(r); err != nil {
+		t.Fatal(err)
+	}
+
+	// the resolver is chained with the generated one
+	b, err := msgpack.Encode(&registered{Int: 3})
+	if err != nil || !bytes.Equal(b, []byte{3}) {
+		t.Errorf("encoded bytes are wrong %x %v", b, err)
+	}
+	b, err = msgpack.AppendEncode([]byte{1}, registered{Int: 2})
+	if err != nil || !bytes.Equal(b, []byte{1, 2}) {
+		t.Errorf("appended bytes are wrong %x %v", b, err)
+	}
+	var v registered
+	if err := msgpack.Decode([]byte{4}, &v); err != nil || v.Int != 4 {
+		t.Error("decoded value is wrong", v, err)
+	}
+	b, err = msgpack.Encode(TestingItem{Price: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var item TestingItem
+	if err := msgpack.Decode(b, &item); err != nil || item.Price != 5 {
+		t.Error("decoded value is wrong", item, err)
+	}
+
+	// a type resolved by the generated resolver can not be registered again
+	r.Name = "conflict"
+	r.Types = append(r.Types, reflect.TypeOf(TestingItem{}))
+	if err := msgpack.Register(r); err == nil || !strings.Contains(err.Error(), "is registered by both") {
+		t.Error("conflict error should occur", err)
+	}
+
+	// shared types are registered by several resolvers, and tried in order
+	type shared struct{ Int int }
+	skip := func(interface{}) ([]byte, error) { return nil, nil }
+	first := msgpack.Resolver{
+		Name:          "shared first",
+		SharedTypes:   []reflect.Type{reflect.TypeOf(shared{}), reflect.TypeOf(struct{ Int int }{})},
+		EncodeAsMap:   skip,
+		EncodeAsArray: skip,
+		DecodeAsMap:   decode,
+		DecodeAsArray: decode,
+	}
+	second := first
+	second.Name = "shared second"
+	second.EncodeAsMap = func(i interface{}) ([]byte, error) {
+		if v, ok := i.(shared); ok {
+			return []byte{byte(v.Int)}, nil
+		}
+		return nil, nil
+	}
+	second.EncodeAsArray = second.EncodeAsMap
+	if err := msgpack.Register(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgpack.Register(second); err != nil {
+		t.Fatal(err)
+	}
+	b, err = msgpack.Encode(shared{Int: 6})
+	if err != nil || !bytes.Equal(b, []byte{6}) {
+		t.Errorf("encoded bytes are wrong %x %v", b, err)
+	}
+	// the anonymous struct is shared with the generated resolver registered before
+	b, err = msgpack.Encode(struct{ Int int }{Int: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var anonymous struct{ Int int }
+	if err := msgpack.Decode(b, &anonymous); err != nil || anonymous.Int != 7 {
+		t.Error("decoded value is wrong", anonymous, err)
+	}
+
+	// the strict generated resolver is chained with the others, and is strict only in its packages
+	b, err = msgpack.Encode(image.Rectangle{Max: image.Point{X: 8}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rect image.Rectangle
+	if err := msgpack.Decode(b, &rect); err != nil || rect.Max.X != 8 {
+		t.Error("decoded value is wrong", rect, err)
+	}
+	if _, err := msgpack.Encode(NotGenerated1{}); err == nil || !strings.Contains(err.Error(), "use strict option") {
+		t.Error("strict error should occur", err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	v := TestingOrderText{Items: []*TestingItemText{{Price: "1"}, {Price: "2"}}}
+	asMap, err := msgpack.EncodeAsMap(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	asArray, err := msgpack.EncodeAsArray(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// layouts are chosen per codec, not by the package setting
+	global := msgpack.StructAsArray()
+	arrayCodec := msgpack.NewCodec(msgpack.Options{StructAsArray: true})
+	mapCodec := msgpack.NewCodec(msgpack.Options{})
+	if b, err := arrayCodec.Encode(v); err != nil || !bytes.Equal(b, asArray) {
+		t.Errorf("array bytes are wrong %x %v", b, err)
+	}
+	if b, err := mapCodec.AppendEncode(nil, v); err != nil || !bytes.Equal(b, asMap) {
+		t.Errorf("map bytes are wrong %x %v", b, err)
+	}
+	var u TestingOrderText
+	if err := msgpack.DecodeWithOptions(asArray, &u, msgpack.Options{StructAsArray: true}); err != nil || !reflect.DeepEqual(v, u) {
+		t.Error("value different", v, u, err)
+	}
+	if msgpack.StructAsArray() != global {
+		t.Error("package setting is changed")
+	}
+
+	limits := dec.Limits{MaxCollectionLength: 1}
+	var le *dec.LimitError
+	if err := mapCodec.Decode(asMap, &TestingOrderText{}); err != nil {
+		t.Error(err)
+	}
+	if err := msgpack.DecodeWithOptions(asMap, &TestingOrderText{}, msgpack.Options{Limits: &limits}); !errors.As(err, &le) {
+		t.Error("error is not limit", err)
+	}
+
+	b, err := mapCodec.Encode(TestingTime{Time: time.Unix(1, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tm TestingTime
+	if err := msgpack.DecodeWithOptions(b, &tm, msgpack.Options{DecodedTimeAsUTC: true}); err != nil || tm.Time.Location() != time.UTC {
+		t.Error("time is not decoded in UTC", tm.Time, err)
+	}
+	if err := mapCodec.Decode(b, &tm); err != nil || tm.Time.Location() != time.Local {
+		t.Error("time is not decoded in local", tm.Time, err)
+	}
+
+	// the resolver of notResolved declines it, so that it falls back to shamaton/msgpack
+	type notResolved struct{ Int int }
+	if err := msgpack.Register(msgpack.Resolver{
+		Name:          "options test",
+		Types:         []reflect.Type{reflect.TypeOf(notResolved{})},
+		EncodeAsMap:   func(interface{}) ([]byte, error) { return nil, nil },
+		EncodeAsArray: func(interface{}) ([]byte, error) { return nil, nil },
+		DecodeAsMap:   func(*dec.Decoder, interface{}) (bool, error) { return false, nil },
+		DecodeAsArray: func(*dec.Decoder, interface{}) (bool, error) { return false, nil },
+	}); err != nil {
+		t.Fatal(err)
+	}
+	b, err = mapCodec.Encode(notResolved{Int: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noFallback := msgpack.NewCodec(msgpack.Options{DisableFallback: true})
+	if _, err := noFallback.Encode(notResolved{}); !errors.Is(err, msgpack.ErrNotResolved) {
+		t.Error("error is not not resolved", err)
+	}
+	if err := noFallback.Decode(b, &notResolved{}); !errors.Is(err, msgpack.ErrNotResolved) {
+		t.Error("error is not not resolved", err)
+	}
+
+	// values in interface{} fields are encoded with the options of the call
+	nested := TestingInterface{Struct: notResolved{Int: 1}}
+	if _, err := mapCodec.Encode(nested); err != nil {
+		t.Error(err)
+	}
+	if _, err := noFallback.Encode(nested); !errors.Is(err, msgpack.ErrNotResolved) {
+		t.Error("error is not not resolved", err)
+	}
+	if _, err := noFallback.AppendEncode(nil, &nested); !errors.Is(err, msgpack.ErrNotResolved) {
+		t.Error("error is not not resolved", err)
+	}
+}
+
+type decimalExt struct{}
+
+func (decimalExt) ExtSize(v Decimal) (int, error) { return 9, nil }
+
+func (decimalExt) WriteExt(v Decimal, b []byte) error {
+	binary.BigEndian.PutUint64(b, uint64(v.Unscaled))
+	b[8] = v.Scale
+	return nil
+}
+
+func (decimalExt) ReadExt(data []byte) (Decimal, error) {
+	if len(data) != 9 {
+		return Decimal{}, fmt.Errorf("decimal length %d", len(data))
+	}
+	return Decimal{Unscaled: int64(binary.BigEndian.Uint64(data)), Scale: data[8]}, nil
+}
+
+type moneyExt struct{}
+
+func (moneyExt) ExtSize(v Money) (int, error) { return 8 + len(v.Currency), nil }
+
+func (moneyExt) WriteExt(v Money, b []byte) error {
+	binary.BigEndian.PutUint64(b, uint64(v.Amount))
+	copy(b[8:], v.Currency)
+	return nil
+}
+
+func (moneyExt) ReadExt(data []byte) (Money, error) {
+	if len(data) < 8 {
+		return Money{}, fmt.Errorf("money length %d", len(data))
+	}
+	return Money{Currency: string(data[8:]), Amount: int64(binary.BigEndian.Uint64(data))}, nil
+}
+
+func TestExt(t *testing.T) {
+	if err := msgpack.RegisterExt[Decimal](5, decimalExt{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgpack.RegisterExt[Money](6, moneyExt{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgpack.RegisterExt[Money](5, moneyExt{}); err == nil {
+		t.Error("ext type registered for two types")
+	}
+	if err := msgpack.RegisterExt[Decimal](7, decimalExt{}); err == nil {
+		t.Error("type registered for two ext types")
+	}
+	if err := msgpack.RegisterExt[Decimal](-1, decimalExt{}); err == nil {
+		t.Error("reserved ext type registered")
+	}
+
+	v := TestingExt{
+		Price:    Decimal{Unscaled: 12345, Scale: 2},
+		Discount: &Decimal{Unscaled: -5, Scale: 1},
+		Total:    Money{Currency: "JPY", Amount: 1000},
+		// fixext8 and ext8
+		Totals:    []Money{{Amount: 1}, {Currency: "USD", Amount: 2}},
+		Interface: Decimal{Unscaled: 1},
+	}
+	for _, asArray := range []bool{true, false} {
+		b, err := msgpack.EncodeAsMap(v)
+		if asArray {
+			b, err = msgpack.EncodeAsArray(v)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var u TestingExt
+		if asArray {
+			err = msgpack.DecodeAsArray(b, &u)
+		} else {
+			err = msgpack.DecodeAsMap(b, &u)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v, u) {
+			t.Error("value different", v, u)
+		}
+	}
+
+	// unknown fields of ext types are skipped
+	b, err := msgpack.EncodeAsMap(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msgpack.DecodeAsMap(b, &Recursive{}); err != nil {
+		t.Error(err)
+	}
+
+	// fixext8 of ext type 6
+	d := dec.NewDecoder([]byte{0xd7, 6, 0, 0, 0, 0, 0, 0, 0, 1})
+	if _, _, err := dec.AsExt[Decimal](d, 5, 0); err == nil || !strings.Contains(err.Error(), "ext type is different 6, 5") {
+		t.Error("ext type error should occur", err)
+	}
+	if m, _, err := d.AsInterface(0); err != nil || m != (Money{Amount: 1}) {
+		t.Error("ext is not decoded to interface", m, err)
+	}
+
+	// negative length is an error instead of panic
+	if err := enc.SetExtEncoder[sizedExtValue](9, sizedExt{}); err != nil {
+		t.Fatal(err)
+	}
+	e := enc.NewEncoder()
+	if _, err := enc.CalcExt(e, 9, sizedExtValue(-1)); err == nil {
+		t.Error("negative length should be error")
+	}
+	e.MakeBytes(16)
+	if _, err := enc.WriteExt(e, 9, sizedExtValue(-1), 0); err == nil {
+		t.Error("negative length should be error")
+	}
+	if _, err := msgpack.Encode(TestingExt{Interface: sizedExtValue(-1)}); err == nil {
+		t.Error("negative length should be error")
+	}
+}
+
+// complexExtValue can not be registered at the ext type of complex numbers of a resolver.
+type complexExtValue struct{}
+
+type complexExt struct{}
+
+func (complexExt) ExtSize(v complexExtValue) (int, error) { return 0, nil }
+
+func (complexExt) WriteExt(v complexExtValue, b []byte) error { return nil }
+
+func (complexExt) ReadExt(data []byte) (complexExtValue, error) { return complexExtValue{}, nil }
+
+// sizedExtValue is written as the ext data of its length.
+type sizedExtValue int
+
+type sizedExt struct{}
+
+func (sizedExt) ExtSize(v sizedExtValue) (int, error) { return int(v), nil }
+
+func (sizedExt) WriteExt(v sizedExtValue, b []byte) error { return nil }
+
+func TestComplexTypeCode(t *testing.T) {
+	defer func() {
+		if err := msgpack.SetComplexTypeCode(enc.DefaultComplexTypeCode); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if err := msgpack.SetComplexTypeCode(3); err != nil {
+		t.Fatal(err)
+	}
+	if code := msgpack.ComplexTypeCode(); code != 3 {
+		t.Error("complex type code is different", code)
+	}
+
+	v := TestingValue{Complex64: complex(1, 2), Complex128: complex(3, 4)}
+	if err := checkValue(v); err != nil {
+		t.Error(err)
+	}
+	b, err := msgpack.Encode(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte{def.Fixext8, 3}) || !bytes.Contains(b, []byte{def.Fixext16, 3}) {
+		t.Error("complex is not encoded as ext type 3")
+	}
+
+	// decoders use the same code
+	if err := msgpack.SetComplexTypeCode(enc.DefaultComplexTypeCode); err != nil {
+		t.Fatal(err)
+	}
+	var u TestingValue
+	if err := msgpack.Decode(b, &u); err == nil {
+		t.Error("complex of ext type 3 should not be decoded")
+	}
+
+	if err := msgpack.SetComplexTypeCode(def.TimeStamp); err == nil {
+		t.Error("timestamp type set to complex")
+	}
+	if err := msgpack.RegisterExt[Decimal](5, decimalExt{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := msgpack.SetComplexTypeCode(5); err == nil {
+		t.Error("registered ext type set to complex")
+	}
+
+	// the resolver carries its own type, and the package setting is not changed
+	type resolved struct{ C complex64 }
+	appendFunc := func(e *enc.Encoder, dst []byte, i interface{}) ([]byte, error) {
+		v, ok := i.(resolved)
+		if !ok {
+			return nil, nil
+		}
+		e.AppendBytes(dst, e.CalcComplex64(v.C))
+		e.WriteComplex64(v.C, len(dst))
+		return e.EncodedBytes(), nil
+	}
+	decode := func(d *dec.Decoder, i interface{}) (bool, error) {
+		v, ok := i.(*resolved)
+		if !ok {
+			return false, nil
+		}
+		c, _, err := d.AsComplex64(0)
+		v.C = c
+		return true, err
+	}
+	code := int8(7)
+	r := msgpack.Resolver{
+		Name:            "complex test",
+		Types:           []reflect.Type{reflect.TypeOf(resolved{})},
+		ComplexTypeCode: &code,
+		AppendAsMap:     appendFunc,
+		AppendAsArray:   appendFunc,
+		DecodeAsMap:     decode,
+		DecodeAsArray:   decode,
+	}
+	if err := msgpack.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	b, err = msgpack.Encode(resolved{C: complex(1, 2)})
+	if err != nil || !bytes.HasPrefix(b, []byte{def.Fixext8, 7}) {
+		t.Errorf("complex is not encoded as ext type 7 %x %v", b, err)
+	}
+	var rv resolved
+	if err := msgpack.Decode(b, &rv); err != nil || rv.C != complex(1, 2) {
+		t.Error("decoded value is wrong", rv, err)
+	}
+	if code := msgpack.ComplexTypeCode(); code != enc.DefaultComplexTypeCode {
+		t.Error("complex type code is changed", code)
+	}
+	// the type of the resolver is not decoded as an ext type
+	if err := msgpack.RegisterExt[complexExtValue](7, complexExt{}); err == nil {
+		t.Error("ext type of complex of the resolver is registered")
+	}
+	if err := dec.SetComplexTypeCode(def.TimeStamp); err == nil {
+		t.Error("timestamp type set to complex of decoders")
+	}
+	if err := dec.SetComplexTypeCode(5); err == nil {
+		t.Error("registered ext type set to complex of decoders")
+	}
+
+	timestamp := int8(def.TimeStamp)
+	r.ComplexTypeCode = &timestamp
+	if err := msgpack.Register(r); err == nil {
+		t.Error("timestamp type set to complex of the resolver")
+	}
+}
+
+func TestStream(t *testing.T) {
+	values := []TestingOrderText{
+		{Items: []*TestingItemText{{Price: "small"}}},
+		// larger than the buffer of the decoder
+		{Items: []*TestingItemText{{Price: strings.Repeat("large", 2000)}}},
+		{Prices: map[string]TestingItemText{"a": {Price: "map"}}},
+	}
+
+	var buf bytes.Buffer
+	e := msgpack.NewEncoder(&buf)
+	for _, v := range values {
+		if err := e.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream := buf.Bytes()
+
+	readers := map[string]func() io.Reader{
+		"bytes":    func() io.Reader { return bytes.NewReader(stream) },
+		"one byte": func() io.Reader { return iotest.OneByteReader(bytes.NewReader(stream)) },
+	}
+	for name, reader := range readers {
+		t.Run(name, func(t *testing.T) {
+			d := msgpack.NewDecoder(reader())
+			decoded := make([]TestingOrderText, len(values))
+			for i := range decoded {
+				if err := d.Decode(&decoded[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// strings decoded before are kept
+			if !reflect.DeepEqual(values, decoded) {
+				t.Error("value different", values, decoded)
+			}
+			if err := d.Decode(&TestingOrderText{}); err != io.EOF {
+				t.Error("error is not EOF", err)
+			}
+		})
+	}
+
+	d := msgpack.NewDecoder(bytes.NewReader(stream[:len(stream)-1]))
+	for range values[:len(values)-1] {
+		if err := d.Decode(&TestingOrderText{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := d.Decode(&TestingOrderText{}); err != io.ErrUnexpectedEOF {
+		t.Error("error is not unexpected EOF", err)
+	}
+
+	// the stream is not framed after a malformed message, even if the error is in the middle of it
+	item, err := msgpack.Encode(TestingItemText{Price: "price"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inner []byte
+	inner = append(inner, 0x92, 0xc1)
+	inner = append(inner, item...)
+	inner = append(inner, item...)
+	for name, malformed := range map[string][]byte{
+		"boundary": append(append(append([]byte{}, stream...), 0xc1), stream...),
+		"middle":   append(append([]byte{}, stream...), inner...),
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := msgpack.NewDecoder(bytes.NewReader(malformed))
+			for range values {
+				if err := d.Decode(&TestingOrderText{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var de *dec.DecodeError
+			err := d.Decode(&TestingItemText{})
+			if !errors.As(err, &de) {
+				t.Error("malformed message is decoded", err)
+			}
+			for i := 0; i < 3; i++ {
+				if again := d.Decode(&TestingItemText{}); again != err {
+					t.Error("error is not returned again", again)
+				}
+			}
+		})
+	}
+
+	// lengths are limited before the data is read
+	limits := dec.Limits{MaxStringLength: 1 << 10, MaxCollectionLength: 1 << 10}
+	c := msgpack.NewCodec(msgpack.Options{Limits: &limits})
+	for _, header := range [][]byte{{def.Str32, 0x40, 0, 0, 0}, {def.Bin32, 0x40, 0, 0, 0}, {def.Array32, 0x40, 0, 0, 0}, {def.Map32, 0x40, 0, 0, 0}, {def.Ext32, 0x40, 0, 0, 0, 1}} {
+		r := &countingReader{r: io.MultiReader(bytes.NewReader(header), zeroReader{})}
+		var le *dec.LimitError
+		if err := c.NewDecoder(r).Decode(&TestingOrderText{}); !errors.As(err, &le) {
+			t.Errorf("%x: limit error should occur %v", header, err)
+		}
+		if r.n > 1<<16 {
+			t.Errorf("%x: %d bytes are read", header, r.n)
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestDirective(t *testing.T) {
+	v := TestingDirective{
+		Array:  DirectiveArray{Int: rand.Int(), String: "array"},
+		Map:    &DirectiveMap{Int: rand.Int(), String: "map"},
+		Key:    DirectiveKey{UserID: rand.Int(), HTTPServer: "server", Tagged: rand.Int()},
+		Inside: Inside{Int: rand.Int()},
+	}
+	var v1, v2 TestingDirective
+	if err := _checkValue(v, &v1, &v2); err != nil {
+		t.Error(err)
+	}
+
+	// ignored
+	if _, err := msgpack.Encode(IgnoredByDirective{}); err == nil {
+		t.Error("ignored struct is encoded")
+	}
+
+	toInterface := func(b []byte) map[interface{}]interface{} {
+		m, _, err := dec.NewDecoder(b).AsInterface(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.(map[interface{}]interface{})
+	}
+
+	// the layout of others depends on the mode
+	b, err := msgpack.EncodeAsMap(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := toInterface(b)
+	if _, ok := m["Array"].([]interface{}); !ok {
+		t.Errorf("array directive is not applied %v", m["Array"])
+	}
+	if _, ok := m["Inside"].(map[interface{}]interface{}); !ok {
+		t.Errorf("inside is not map %v", m["Inside"])
+	}
+	key, ok := m["Key"].(map[interface{}]interface{})
+	if !ok {
+		t.Fatalf("key is not map %v", m["Key"])
+	}
+	var keys []string
+	for k := range key {
+		keys = append(keys, k.(string))
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, []string{"Tagged", "http_server", "user_id"}) {
+		t.Errorf("keys are wrong %v", keys)
+	}
+
+	b, err = msgpack.EncodeAsArray(v.Map)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := toInterface(b); m["String"] != "map" {
+		t.Errorf("map directive is not applied %v", m)
+	}
+}
+
+func TestAlias(t *testing.T) {
+	v := TestingAlias{
+		Inside:    AliasInside{Int: rand.Int()},
+		Int:       rand.Int(),
+		Ints:      []AliasInt{1, 2},
+		Versioned: &AliasVersioned{},
+		Point:     image.Point{X: 1, Y: -1},
+		Page:      StringsPage{Items: [][]string{{"a"}}},
+		Anonymous: AliasAnonymous{Int: rand.Int()},
+		Map:       map[AliasInt]AliasInside{1: {Int: 1}},
+	}
+	var v1, v2 TestingAlias
+	if err := _checkValue(v, &v1, &v2); err != nil {
+		t.Error(err)
+	}
+
+	// target out of the input directory
+	p := image.Point{X: rand.Int(), Y: rand.Int()}
+	var p1, p2 image.Point
+	if err := _checkValue(p, &p1, &p2); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestTag(t *testing.T) {
 	v := TestingTag{Tag: 1, Ignore: rand.Int(), Omit: rand.Int()}
 	b1, b2, e1, e2 := marshal(v, v)
@@ -864,3 +2279,46 @@ func marshal(v1, v2 interface{}) ([]byte, []byte, error, error) {
 func unmarshal(b1, b2 []byte, v1, v2 interface{}) (error, error) {
 	return msgpack.Decode(b1, v1), msgpack.DecodeAsArray(b2, v2)
 }
+
+func BenchmarkEncodeTestingValue(b *testing.B) {
+	v := &TestingValue{Int: -1, Uint64: math.MaxUint64, Float64: math.Pi, String: "string", Bool: true, Complex128: complex(1, 2)}
+	benchmarkEncode(b, v)
+}
+
+func BenchmarkEncodeTestingStruct(b *testing.B) {
+	v := &TestingStruct{Int: 1, Inside: Inside{Int: 2}, R: &Recursive{Int: 3}}
+	benchmarkEncode(b, v)
+}
+
+// benchmarkEncode compares new bytes for each encoding with bytes released to the pool and appended to a buffer.
+func benchmarkEncode(b *testing.B, v interface{}) {
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := msgpack.Encode(v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("release", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bs, err := msgpack.Encode(v)
+			if err != nil {
+				b.Fatal(err)
+			}
+			msgpack.Release(bs)
+		}
+	})
+	b.Run("append", func(b *testing.B) {
+		b.ReportAllocs()
+		var buf []byte
+		for i := 0; i < b.N; i++ {
+			bs, err := msgpack.AppendEncode(buf[:0], v)
+			if err != nil {
+				b.Fatal(err)
+			}
+			buf = bs
+		}
+	})
+}
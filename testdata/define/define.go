@@ -10,5 +10,9 @@ type A struct {
 }
 
 type NotGeneratedChild struct {
-	Interface interface{}
+	Interface interface{ Method() }
+}
+
+type Wrapper[T any] struct {
+	Value T
 }
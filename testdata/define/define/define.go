@@ -11,3 +11,9 @@ type DotImport struct {
 type Time struct {
 	Int int
 }
+
+type TimeHolder struct {
+	Time Time
+}
+
+type Level int8
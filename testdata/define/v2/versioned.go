@@ -0,0 +1,5 @@
+package versioned
+
+type Versioned struct {
+	Int int
+}